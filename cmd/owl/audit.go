@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eyazici90/owl/internal"
+	"github.com/urfave/cli/v2"
+)
+
+var auditCmd = &cli.Command{
+	Name:   "audit",
+	Usage:  `Exports everything, runs all analysers & writes a single html report`,
+	Action: actionAudit,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "prom-addr",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "grafana-addr",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name: "svc-token",
+		},
+		&cli.StringFlag{
+			Name:  "since",
+			Value: "720h",
+		},
+		&cli.StringFlag{
+			Name:  "snapshot",
+			Usage: "snapshot directory to export into",
+			Value: "snapshot",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-export",
+			Usage: "analyse the existing snapshot without exporting into it",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   "report.html",
+		},
+		&cli.StringFlag{
+			Name:  "vars-file",
+			Usage: "yaml file of grafana variable values used while parsing queries",
+		},
+		&cli.Uint64Flag{
+			Name:  "limit",
+			Value: 100,
+		},
+	},
+}
+
+func actionAudit(c *cli.Context) error {
+	cfg := actionSetup(c)
+	if !cfg.AuditConfig.SkipExport && cfg.AuditConfig.SvcToken == "" {
+		return fmt.Errorf("svc-token is required unless skip-export")
+	}
+	rep, err := internal.NewAuditor(cfg.AuditConfig).Run(c.Context)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	for _, pe := range rep.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	slog.Info("Audit finished",
+		slog.String("report", cfg.AuditConfig.Output),
+		slog.Int("idle-metrics", len(rep.IdleMetrics)),
+		slog.Int("idle-rules", len(rep.IdleRules)),
+		slog.Int("idle-dashboards", len(rep.IdleDashboards)),
+		slog.Int("err-count", len(rep.ParseErrs)),
+		slog.Int("failed-expr-count", rep.FailedExprsCount),
+	)
+	return nil
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"strconv"
+	"time"
 
 	"github.com/eyazici90/owl/internal"
 	"github.com/urfave/cli/v2"
@@ -16,6 +18,10 @@ var dashboardsCmd = &cli.Command{
 			Usage:  `exports grafana dashboards to csv file`,
 			Action: actionDashboardsExport,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to export into instead of the csv output",
+				},
 				&cli.StringFlag{
 					Name:    "output",
 					Aliases: []string{"o"},
@@ -31,15 +37,88 @@ var dashboardsCmd = &cli.Command{
 				},
 			},
 		},
+		{
+			Name:   "backup",
+			Usage:  `Backs up the full json model of grafana dashboards into a directory per folder`,
+			Action: actionDashboardsBackup,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "dir",
+					Usage:    "directory the dashboards are written into, dashboards whose version didn't change are skipped",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "addr",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "svc-token",
+					Required: true,
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+			},
+		},
+		{
+			Name:   "restore",
+			Usage:  `Restores the dashboards of a backup directory to grafana keeping their uids`,
+			Action: actionDashboardsRestore,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "dir",
+					Usage:    "backup directory the dashboards are read from",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "addr",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "svc-token",
+					Required: true,
+				},
+				&cli.Int64Flag{
+					Name:  "org-id",
+					Usage: "organization to restore into, the one of the token if not set",
+				},
+				&cli.StringFlag{
+					Name:  "policy",
+					Usage: "what to do with dashboards that exist with changes, skip or overwrite",
+					Value: internal.RestoreSkip,
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only report the folders & dashboards that would change and how",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+			},
+		},
 		{
 			Name:   "top-used",
 			Usage:  `Lists metrics & rules that are used most in the grafana dashboards`,
 			Action: actionDashboardsTopUsed,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
 				&cli.StringFlag{
 					Name:  "dashboards-file",
 					Value: "dashboards.csv",
 				},
+				&cli.StringFlag{
+					Name:  "metrics-file",
+					Value: "metrics.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
 				&cli.Uint64Flag{
 					Name:  "limit",
 					Value: 10,
@@ -51,6 +130,10 @@ var dashboardsCmd = &cli.Command{
 			Usage:  `Find panels in the dashboard whose metrics don't exist anymore'`,
 			Action: actionDashboardsIdle,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
 				&cli.StringFlag{
 					Name:  "dashboards-file",
 					Value: "dashboards.csv",
@@ -63,6 +146,264 @@ var dashboardsCmd = &cli.Command{
 					Name:  "metrics-file",
 					Value: "metrics.csv",
 				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+				&cli.BoolFlag{
+					Name:  "live",
+					Usage: "validates selectors against prometheus to find ones matching no series",
+				},
+				&cli.StringFlag{
+					Name:  "addr",
+					Usage: "prometheus address, required in live mode",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "suggest-recordings",
+			Usage:  `Suggests recording rules for expensive subexpressions repeated across dashboards`,
+			Action: actionDashboardsSuggestRecordings,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.StringFlag{
+					Name:  "metrics-file",
+					Value: "metrics.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+				&cli.StringFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "rule file the suggested recording rules are written into",
+					Value:   "recordings.yaml",
+				},
+				&cli.StringFlag{
+					Name:  "group",
+					Value: "dashboard-recordings",
+				},
+				&cli.Uint64Flag{
+					Name:  "min-occurrences",
+					Usage: "number of targets a subexpression has to be used in",
+					Value: 2,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "use-recordings",
+			Usage:  `Finds dashboard queries that could select existing recording rules instead`,
+			Action: actionDashboardsUseRecordings,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+			},
+		},
+		{
+			Name:   "profile",
+			Usage:  `Runs the panel queries as range queries & ranks dashboards and panels by their cost`,
+			Action: actionDashboardsProfile,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "addr",
+					Usage:    "prometheus address the queries are run against",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+				&cli.StringFlag{
+					Name:  "window",
+					Usage: "time range the queries are run over",
+					Value: "6h",
+				},
+				&cli.DurationFlag{
+					Name:  "step",
+					Value: 30 * time.Second,
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "load",
+			Usage:  `Estimates the query load of dashboards from their refresh, time range & panel settings`,
+			Action: actionDashboardsLoad,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.DurationFlag{
+					Name:  "min-refresh",
+					Usage: "shortest refresh interval not considered aggressive",
+					Value: 30 * time.Second,
+				},
+				&cli.DurationFlag{
+					Name:  "long-range",
+					Usage: "time range from which on refreshing faster than 10x min-refresh is considered aggressive",
+					Value: 24 * time.Hour,
+				},
+				&cli.Float64Flag{
+					Name:  "max-queries-per-min",
+					Value: 60,
+				},
+				&cli.DurationFlag{
+					Name:  "scrape-interval",
+					Usage: "query step of panels without an interval",
+					Value: 15 * time.Second,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "panels",
+			Usage:  `Counts panel types & lists the dashboards whose deprecated angular panels block a grafana upgrade`,
+			Action: actionDashboardsPanels,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "library-panels",
+			Usage:  `Lists the library panels in use & the dashboards using each of them`,
+			Action: actionDashboardsLibraryPanels,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "structure",
+			Usage:  `Audits dashboards for old schema versions, duplicate panel ids & missing, empty or hidden queries`,
+			Action: actionDashboardsStructure,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.UintFlag{
+					Name:  "min-schema-version",
+					Usage: "oldest schema version not reported, 30 is the one of grafana 8",
+					Value: 30,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "stale",
+			Usage:  `Finds dashboards nobody has edited or viewed for days & scores how abandoned they are`,
+			Action: actionDashboardsStale,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.StringFlag{
+					Name:  "metrics-file",
+					Value: "metrics.csv",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+				&cli.UintFlag{
+					Name:  "days",
+					Usage: "days without an edit from which on a dashboard is considered stale, views are grafana's of the last 30 days & only rule dashboards out from 30 days on",
+					Value: 90,
+				},
 				&cli.Uint64Flag{
 					Name:  "limit",
 					Value: 10,
@@ -92,6 +433,78 @@ func actionDashboardsExport(c *cli.Context) error {
 	return nil
 }
 
+func actionDashboardsBackup(c *cli.Context) error {
+	cfg := actionSetup(c)
+	db := internal.NewDashboardsBackuper(cfg.DashboardsBackupConfig)
+	res, err := db.Backup(c.Context)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, be := range res.Written {
+		slog.Info("Backup",
+			slog.String("uid", be.UID),
+			slog.String("title", be.Title),
+			slog.String("folder", be.Folder),
+			slog.Int64("version", be.Version),
+			slog.String("path", be.Path),
+		)
+	}
+	slog.Info("Finished",
+		slog.Int("total", res.Total),
+		slog.Int("written", len(res.Written)),
+		slog.Int("unchanged", res.Unchanged),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsRestore(c *cli.Context) error {
+	cfg := actionSetup(c)
+	dr := internal.NewDashboardsRestorer(cfg.DashboardsRestoreConfig)
+	res, err := dr.Restore(c.Context)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, rf := range res.Folders {
+		slog.Info("Folder",
+			slog.String("uid", rf.UID),
+			slog.String("title", rf.Title),
+			slog.String("parent-uid", rf.ParentUID),
+		)
+	}
+	actions := make(map[string]int)
+	for _, rd := range res.Dashboards {
+		actions[rd.Action]++
+		if rd.Action == internal.RestoreActionUnchanged {
+			continue
+		}
+		slog.Info("Restore",
+			slog.String("action", rd.Action),
+			slog.String("uid", rd.UID),
+			slog.String("title", rd.Title),
+			slog.String("folder", rd.Folder),
+			slog.Any("changes", rd.Changes),
+		)
+	}
+	slog.Info("Finished",
+		slog.Bool("dry-run", cfg.DashboardsRestoreConfig.DryRun),
+		slog.Int("total", res.Total),
+		slog.Int("folders", len(res.Folders)),
+		slog.Int("created", actions[internal.RestoreActionCreate]),
+		slog.Int("overwritten", actions[internal.RestoreActionOverwrite]),
+		slog.Int("skipped", actions[internal.RestoreActionSkip]),
+		slog.Int("unchanged", actions[internal.RestoreActionUnchanged]),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
 func actionDashboardsTopUsed(c *cli.Context) error {
 	cfg := actionSetup(c)
 	tl := internal.NewTopUsedListerInGrafana(cfg.TopListerConfig)
@@ -110,12 +523,16 @@ func actionDashboardsTopUsed(c *cli.Context) error {
 	slog.Info("Found",
 		slog.Int("total", len(res.Usages)),
 		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
 	)
 	return nil
 }
 
 func actionDashboardsIdle(c *cli.Context) error {
 	cfg := actionSetup(c)
+	if cfg.IdlerConfig.Live != nil && cfg.IdlerConfig.Live.Addr == "" {
+		return fmt.Errorf("addr is required in live mode")
+	}
 	dsi := internal.NewDashboardsIdler(cfg.IdlerConfig)
 	res, err := dsi.List(c.Context)
 	if err != nil {
@@ -129,9 +546,324 @@ func actionDashboardsIdle(c *cli.Context) error {
 			slog.String("item", fmt.Sprintf("%+v", ds)),
 		)
 	}
+	for _, es := range res.EmptySelectors {
+		slog.Info("Empty",
+			slog.String("uid", es.Board.UID),
+			slog.String("title", es.Board.Title),
+			slog.Uint64("panel-id", uint64(es.Panel.ID)),
+			slog.String("panel", es.Panel.Title),
+			slog.Any("selectors", es.Selectors),
+		)
+	}
 	slog.Info("Found",
 		slog.Int("total", len(res.IdleDashboards)),
 		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+		slog.Int("empty-total", len(res.EmptySelectors)),
 	)
 	return nil
 }
+
+func actionDashboardsSuggestRecordings(c *cli.Context) error {
+	cfg := actionSetup(c)
+	rs := internal.NewDashboardsRecordingSuggester(cfg.SuggestRecordingsConfig)
+	res, err := rs.Suggest(c.Context)
+	if err != nil {
+		return fmt.Errorf("suggest recordings: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, s := range res.Suggestions {
+		slog.Info("Suggestion",
+			slog.String("record", s.Record),
+			slog.String("expr", s.Expr),
+			slog.Int("occurrences", s.Occurrences),
+			slog.Float64("cost", s.Cost),
+			slog.Float64("score", s.Score),
+			slog.Any("dashboards", panelRefs(s.Dashboards)),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Suggestions)),
+		slog.String("output", cfg.SuggestRecordingsConfig.Output),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsUseRecordings(c *cli.Context) error {
+	cfg := actionSetup(c)
+	rr := internal.NewDashboardsRecordingRewriter(cfg.RecordingRewritesConfig)
+	res, err := rr.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list recording rewrites: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	panels := make(map[string]struct{})
+	for _, rw := range res.Rewrites {
+		panels[fmt.Sprintf("%s/%d", rw.Board.UID, rw.Panel.ID)] = struct{}{}
+		slog.Info("Rewrite",
+			slog.String("uid", rw.Board.UID),
+			slog.String("title", rw.Board.Title),
+			slog.Uint64("panel-id", uint64(rw.Panel.ID)),
+			slog.String("panel-title", rw.Panel.Title),
+			slog.String("expr", rw.Expr),
+			slog.String("subexpr", rw.Subexpr),
+			slog.String("record", rw.Record),
+			slog.Any("filters", rw.Filters),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Rewrites)),
+		slog.Int("panels", len(panels)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsProfile(c *cli.Context) error {
+	cfg := actionSetup(c)
+	dp := internal.NewDashboardsProfiler(cfg.ProfileConfig)
+	res, err := dp.Profile(c.Context)
+	if err != nil {
+		return fmt.Errorf("profile: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, bp := range res.Boards {
+		slog.Info("Dashboard",
+			slog.String("uid", bp.Board.UID),
+			slog.String("title", bp.Board.Title),
+			slog.Int("panels", bp.Panels),
+			slog.Int("queries", bp.Queries),
+			slog.Int64("samples", bp.Samples),
+			slog.Int("series", bp.Series),
+			slog.Duration("wall-time", bp.WallTime),
+			slog.Duration("eval-time", bp.EvalTime),
+		)
+	}
+	for _, pp := range res.Panels {
+		slog.Info("Panel",
+			slog.String("uid", pp.Board.UID),
+			slog.Uint64("panel-id", uint64(pp.Panel.ID)),
+			slog.String("panel-title", pp.Panel.Title),
+			slog.Int("queries", pp.Queries),
+			slog.Int64("samples", pp.Samples),
+			slog.Int("series", pp.Series),
+			slog.Duration("wall-time", pp.WallTime),
+			slog.Duration("eval-time", pp.EvalTime),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Boards)),
+		slog.Int("panels", len(res.Panels)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsLoad(c *cli.Context) error {
+	cfg := actionSetup(c)
+	le := internal.NewDashboardsLoadEstimator(cfg.LoadConfig)
+	res, err := le.Estimate(c.Context)
+	if err != nil {
+		return fmt.Errorf("estimate load: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	var flagged int
+	for _, bl := range res.Loads {
+		if len(bl.Checks) > 0 {
+			flagged++
+		}
+		slog.Info("Load",
+			slog.String("uid", bl.Board.UID),
+			slog.String("title", bl.Board.Title),
+			slog.Duration("refresh", bl.Refresh),
+			slog.Duration("range", bl.Range),
+			slog.Int("queries", bl.Queries),
+			slog.String("queries-per-min", fmt.Sprintf("%.1f", bl.QueriesPerMin)),
+			slog.String("steps-per-query", fmt.Sprintf("%.0f", bl.StepsPerQuery)),
+			slog.String("steps-per-min", fmt.Sprintf("%.0f", bl.StepsPerMin)),
+			slog.Any("checks", bl.Checks),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Loads)),
+		slog.Int("flagged", flagged),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsPanels(c *cli.Context) error {
+	cfg := actionSetup(c)
+	pi := internal.NewPanelsInventory(cfg.PanelsConfig)
+	res, err := pi.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list panels: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	var deprecated int
+	for _, tu := range res.Types {
+		if tu.Deprecated {
+			deprecated += tu.Panels
+		}
+		slog.Info("Type",
+			slog.String("type", tu.Type),
+			slog.Int("panels", tu.Panels),
+			slog.Int("dashboards", tu.Dashboards),
+			slog.Bool("deprecated", tu.Deprecated),
+			slog.String("replacement", tu.Replacement),
+		)
+	}
+	for _, fp := range res.Folders {
+		slog.Info("Folder",
+			slog.String("folder", fp.Folder),
+			slog.Int("dashboards", fp.Dashboards),
+			slog.Int("panels", fp.Panels),
+			slog.Any("types", fp.Types),
+		)
+	}
+	for _, bb := range res.Blocking {
+		ids := make([]uint, 0, len(bb.Board.Panels))
+		for _, p := range bb.Board.Panels {
+			ids = append(ids, p.ID)
+		}
+		slog.Info("Blocking",
+			slog.String("uid", bb.Board.UID),
+			slog.String("title", bb.Board.Title),
+			slog.String("folder", bb.Board.Folder),
+			slog.Any("types", bb.Types),
+			slog.Any("panel-ids", ids),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Types)),
+		slog.Int("deprecated-panels", deprecated),
+		slog.Int("blocking", len(res.Blocking)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsLibraryPanels(c *cli.Context) error {
+	cfg := actionSetup(c)
+	ll := internal.NewLibraryPanelsLister(cfg.LibraryPanelsConfig)
+	res, err := ll.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list library panels: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, lu := range res.Panels {
+		uids := make([]string, 0, len(lu.Dashboards))
+		for _, b := range lu.Dashboards {
+			uids = append(uids, b.UID)
+		}
+		slog.Info("Library",
+			slog.String("uid", lu.UID),
+			slog.String("name", lu.Name),
+			slog.String("type", lu.Type),
+			slog.Bool("resolved", lu.Resolved),
+			slog.Int("instances", lu.Instances),
+			slog.Int("dashboards", len(lu.Dashboards)),
+			slog.Any("dashboard-uids", uids),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Panels)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsStructure(c *cli.Context) error {
+	cfg := actionSetup(c)
+	sa := internal.NewDashboardsStructureAuditor(cfg.StructureConfig)
+	res, err := sa.Audit(c.Context)
+	if err != nil {
+		return fmt.Errorf("audit structure: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	var issues int
+	for _, bi := range res.Boards {
+		issues += len(bi.Issues)
+		for _, is := range bi.Issues {
+			slog.Info("Issue",
+				slog.String("uid", bi.Board.UID),
+				slog.String("title", bi.Board.Title),
+				slog.Uint64("schema-version", uint64(bi.Board.SchemaVersion)),
+				slog.String("check", is.Check),
+				slog.Uint64("panel-id", uint64(is.PanelID)),
+				slog.String("ref-id", is.RefID),
+				slog.String("reason", is.Message),
+			)
+		}
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Boards)),
+		slog.Int("issues", issues),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionDashboardsStale(c *cli.Context) error {
+	cfg := actionSetup(c)
+	sf := internal.NewDashboardsStaleFinder(cfg.StaleConfig)
+	res, err := sf.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list stale dashboards: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, sb := range res.Boards {
+		views := "unknown"
+		if sb.Board.Views != nil {
+			views = strconv.FormatInt(*sb.Board.Views, 10)
+		}
+		slog.Info("Stale",
+			slog.String("uid", sb.Board.UID),
+			slog.String("title", sb.Board.Title),
+			slog.String("folder", sb.Board.Folder),
+			slog.Int("days", int(sb.Age.Hours()/24)),
+			slog.Int64("version", sb.Board.Version),
+			slog.String("views", views),
+			slog.String("missing-metrics", fmt.Sprintf("%d/%d", sb.MissingMetrics, sb.UsedMetrics)),
+			slog.String("score", fmt.Sprintf("%.2f", sb.Score)),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Boards)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
+
+// panelRefs formats boards holding the affected panels only as uid[panel-ids].
+func panelRefs(boards []*internal.Board) []string {
+	res := make([]string, 0, len(boards))
+	for _, b := range boards {
+		ids := make([]uint, 0, len(b.Panels))
+		for _, p := range b.Panels {
+			ids = append(ids, p.ID)
+		}
+		res = append(res, fmt.Sprintf("%s%v", b.UID, ids))
+	}
+	return res
+}
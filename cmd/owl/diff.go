@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/eyazici90/owl/internal"
+	"github.com/urfave/cli/v2"
+)
+
+var diffCmd = &cli.Command{
+	Name:      "diff",
+	Usage:     `Compares two snapshots (or directories of csv exports) & reports what changed`,
+	ArgsUsage: "<old-snapshot> <new-snapshot>",
+	Action:    actionDiff,
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "series-threshold",
+			Usage: "number of series from which on a metric is considered high cardinality",
+			Value: 10000,
+		},
+		&cli.Float64Flag{
+			Name:  "eval-regression",
+			Usage: "growth ratio of a rule's evaluation time that is reported as regression",
+			Value: 1.5,
+		},
+		&cli.StringFlag{
+			Name:  "vars-file",
+			Usage: "yaml file of grafana variable values used while parsing queries",
+		},
+	},
+}
+
+func actionDiff(c *cli.Context) error {
+	cfg := actionSetup(c)
+	if c.NArg() != 2 {
+		return fmt.Errorf("expected 2 arguments, got %d", c.NArg())
+	}
+	cfg.DiffConfig = &internal.DiffConfig{
+		Old:             internal.NewSourceFromDir(c.Args().Get(0)),
+		New:             internal.NewSourceFromDir(c.Args().Get(1)),
+		VarsFile:        c.String("vars-file"),
+		SeriesThreshold: c.Uint64("series-threshold"),
+		EvalRegression:  c.Float64("eval-regression"),
+	}
+	res, err := internal.NewDiffer(cfg.DiffConfig).Diff(c.Context)
+	if err != nil {
+		return fmt.Errorf("diff: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, m := range res.HighCardinalityMetrics {
+		slog.Warn("High cardinality metric",
+			slog.String("metric", string(m.Name)),
+			slog.Uint64("series", m.Series),
+		)
+	}
+	for _, b := range res.BrokenBoards {
+		slog.Warn("Broken dashboard",
+			slog.String("item", fmt.Sprintf("%+v", b)),
+		)
+	}
+	for _, m := range res.AddedMetrics {
+		slog.Info("Metric appeared", slog.String("metric", string(m)))
+	}
+	for _, m := range res.RemovedMetrics {
+		slog.Info("Metric vanished", slog.String("metric", string(m)))
+	}
+	for _, r := range res.AddedRules {
+		slog.Info("Rule added", slog.String("item", fmt.Sprintf("%+v", r)))
+	}
+	for _, r := range res.RemovedRules {
+		slog.Info("Rule removed", slog.String("item", fmt.Sprintf("%+v", r)))
+	}
+	for _, rc := range res.ChangedRules {
+		slog.Info("Rule changed",
+			slog.String("group", rc.New.Group),
+			slog.String("name", rc.New.Name),
+			slog.Any("changes", rc.Changes),
+		)
+	}
+	for _, b := range res.AddedBoards {
+		slog.Info("Dashboard added", slog.String("uid", b.UID), slog.String("title", b.Title))
+	}
+	for _, b := range res.RemovedBoards {
+		slog.Info("Dashboard removed", slog.String("uid", b.UID), slog.String("title", b.Title))
+	}
+	for _, bc := range res.ChangedBoards {
+		slog.Info("Dashboard changed",
+			slog.String("uid", bc.Board.UID),
+			slog.String("title", bc.Board.Title),
+			slog.Any("changes", bc.Changes),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("metrics-added", len(res.AddedMetrics)),
+		slog.Int("metrics-removed", len(res.RemovedMetrics)),
+		slog.Int("high-cardinality", len(res.HighCardinalityMetrics)),
+		slog.Int("rules-added", len(res.AddedRules)),
+		slog.Int("rules-removed", len(res.RemovedRules)),
+		slog.Int("rules-changed", len(res.ChangedRules)),
+		slog.Int("dashboards-added", len(res.AddedBoards)),
+		slog.Int("dashboards-removed", len(res.RemovedBoards)),
+		slog.Int("dashboards-changed", len(res.ChangedBoards)),
+		slog.Int("dashboards-broken", len(res.BrokenBoards)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
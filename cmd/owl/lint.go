@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/eyazici90/owl/internal"
+	"github.com/urfave/cli/v2"
+)
+
+var lintCmd = &cli.Command{
+	Name:   "lint",
+	Usage:  `Checks the queries of prom rules & grafana dashboards for PromQL anti-patterns`,
+	Action: actionLint,
+	Subcommands: []*cli.Command{
+		{
+			Name:   "alerts",
+			Usage:  `Checks alerting rules for missing for durations, labels & annotations`,
+			Action: actionLintAlerts,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringSliceFlag{
+					Name:  "required-labels",
+					Value: cli.NewStringSlice("severity", "team"),
+				},
+				&cli.StringSliceFlag{
+					Name:  "allowed-values",
+					Usage: "allowed values of a label as name=value|value, can be repeated",
+					Value: cli.NewStringSlice("severity=critical|warning|info"),
+				},
+				&cli.StringSliceFlag{
+					Name:  "required-annotations",
+					Value: cli.NewStringSlice("summary", "description", "runbook_url"),
+				},
+			},
+		},
+		{
+			Name:   "naming",
+			Usage:  `Checks recording rule & metric names against the naming conventions & suggests conforming ones`,
+			Action: actionLintNaming,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "dashboards-file",
+					Value: "dashboards.csv",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringFlag{
+					Name:  "metrics-file",
+					Value: "metrics.csv",
+				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "snapshot",
+			Usage: "snapshot directory to read from instead of the csv files",
+		},
+		&cli.StringFlag{
+			Name:  "dashboards-file",
+			Value: "dashboards.csv",
+		},
+		&cli.StringFlag{
+			Name:  "rules-file",
+			Value: "rules.csv",
+		},
+		&cli.StringFlag{
+			Name:  "metrics-file",
+			Value: "metrics.csv",
+		},
+		&cli.StringFlag{
+			Name:  "vars-file",
+			Usage: "yaml file of grafana variable values used while parsing queries",
+		},
+		&cli.DurationFlag{
+			Name:  "scrape-interval",
+			Usage: "scrape interval range windows need to span 4 times",
+			Value: 15 * time.Second,
+		},
+	},
+}
+
+func actionLint(c *cli.Context) error {
+	cfg := actionSetup(c)
+	res, err := internal.NewPromQLLinter(cfg.LintConfig).Lint(c.Context)
+	if err != nil {
+		return fmt.Errorf("lint: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	logLintFindings(res.Findings)
+	slog.Info("Found",
+		slog.Int("total", len(res.Findings)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionLintAlerts(c *cli.Context) error {
+	cfg := actionSetup(c)
+	allowed := make(map[string][]string)
+	for _, av := range c.StringSlice("allowed-values") {
+		name, vals, ok := strings.Cut(av, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid allowed values %q, expected name=value|value", av)
+		}
+		allowed[name] = strings.Split(vals, "|")
+	}
+	cfg.AlertLintConfig = &internal.AlertLintConfig{
+		Source:              cfg.LintConfig.Source,
+		RequiredLabels:      c.StringSlice("required-labels"),
+		AllowedValues:       allowed,
+		RequiredAnnotations: c.StringSlice("required-annotations"),
+	}
+	res, err := internal.NewAlertLinter(cfg.AlertLintConfig).Lint(c.Context)
+	if err != nil {
+		return fmt.Errorf("lint alerts: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	logLintFindings(res.Findings)
+	slog.Info("Found",
+		slog.Int("total", len(res.Findings)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionLintNaming(c *cli.Context) error {
+	cfg := actionSetup(c)
+	res, err := internal.NewNamingLinter(cfg.NamingConfig).Lint(c.Context)
+	if err != nil {
+		return fmt.Errorf("lint naming: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, rn := range res.Renames {
+		rules := make([]string, 0, len(rn.Rules))
+		for _, r := range rn.Rules {
+			rules = append(rules, r.Group+"/"+r.Name)
+		}
+		slog.Info("Rename",
+			slog.String("kind", rn.Kind),
+			slog.String("name", rn.Name),
+			slog.String("suggested", rn.Suggested),
+			slog.Any("checks", rn.Checks),
+			slog.Any("rules", rules),
+			slog.Any("dashboards", panelRefs(rn.Dashboards)),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Renames)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
+
+func logLintFindings(findings []internal.LintFinding) {
+	for _, f := range findings {
+		attrs := []any{
+			slog.String("id", f.ID),
+			slog.String("severity", f.Severity),
+		}
+		if f.Rule != nil {
+			attrs = append(attrs, slog.String("group", f.Rule.Group), slog.String("name", f.Rule.Name))
+		}
+		if f.Board != nil {
+			attrs = append(attrs, slog.String("uid", f.Board.UID), slog.String("title", f.Board.Title))
+		}
+		if f.Panel != nil {
+			attrs = append(attrs, slog.Uint64("panel-id", uint64(f.Panel.ID)))
+		}
+		if f.Expr != "" {
+			attrs = append(attrs, slog.String("expr", f.Expr))
+		}
+		attrs = append(attrs, slog.String("reason", f.Message))
+		slog.Info("Lint", attrs...)
+	}
+}
@@ -16,6 +16,10 @@ var metricsCmd = &cli.Command{
 			Usage:  `exports prom metrics to csv file`,
 			Action: actionMetricsExport,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to export into instead of the csv output",
+				},
 				&cli.StringFlag{
 					Name:    "output",
 					Aliases: []string{"o"},
@@ -29,6 +33,11 @@ var metricsCmd = &cli.Command{
 					Name:  "since",
 					Value: "720h",
 				},
+				&cli.Uint64Flag{
+					Name:  "cardinality-limit",
+					Usage: "number of metrics with the most series to export series counts of, 0 disables",
+					Value: 100,
+				},
 			},
 		},
 		{
@@ -36,6 +45,10 @@ var metricsCmd = &cli.Command{
 			Usage:  `Find metrics that are not used in any grafana dashboards & prom rules`,
 			Action: actionMetricsIdle,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
 				&cli.StringFlag{
 					Name:  "dashboards-file",
 					Value: "dashboards.csv",
@@ -48,6 +61,10 @@ var metricsCmd = &cli.Command{
 					Name:  "metrics-file",
 					Value: "metrics.csv",
 				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
 				&cli.Uint64Flag{
 					Name:  "limit",
 					Value: 10,
@@ -89,6 +106,7 @@ func actionMetricsIdle(c *cli.Context) error {
 	slog.Info("Found",
 		slog.Int("total", len(res.IdleMetrics)),
 		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
 	)
 	return nil
 }
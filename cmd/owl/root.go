@@ -15,6 +15,9 @@ var root = &cli.App{
 		rulesCmd,
 		metricsCmd,
 		dashboardsCmd,
+		diffCmd,
+		auditCmd,
+		lintCmd,
 	},
 	Flags: []cli.Flag{
 		&cli.StringFlag{
@@ -26,11 +29,32 @@ var root = &cli.App{
 
 type Config struct {
 	*internal.ExportConfig
+	*internal.RulesExportConfig
 	*internal.MetricsExporterConfig
 	*internal.DashboardsExportConfig
 	*internal.IdlerConfig
 	*internal.SlowestConfig
 	*internal.TopListerConfig
+	*internal.DiffConfig
+	*internal.AuditConfig
+	*internal.FailingConfig
+	*internal.GroupPressureConfig
+	*internal.AlertHistoryConfig
+	*internal.BacktestConfig
+	*internal.LintConfig
+	*internal.AlertLintConfig
+	*internal.NamingConfig
+	*internal.DuplicatesConfig
+	*internal.SuggestRecordingsConfig
+	*internal.RecordingRewritesConfig
+	*internal.ProfileConfig
+	*internal.LoadConfig
+	*internal.PanelsConfig
+	*internal.LibraryPanelsConfig
+	*internal.StructureConfig
+	*internal.StaleConfig
+	*internal.DashboardsBackupConfig
+	*internal.DashboardsRestoreConfig
 }
 
 func actionSetup(c *cli.Context) *Config {
@@ -46,33 +70,170 @@ func actionSetup(c *cli.Context) *Config {
 	mfile := c.String("metrics-file")
 	dfile := c.String("dashboards-file")
 	token := c.String("svc-token")
+	vfile := c.String("vars-file")
+	snapshot := c.String("snapshot")
 	expr := &internal.ExportConfig{
-		Addr:   addr,
-		Output: out,
+		Addr:     addr,
+		Output:   out,
+		Snapshot: snapshot,
+	}
+	var live *internal.LiveConfig
+	if c.Bool("live") {
+		live = &internal.LiveConfig{
+			Addr:        addr,
+			Concurrency: c.Int("concurrency"),
+		}
+	}
+	src := &internal.Source{
+		Snapshot:       snapshot,
+		RulesFile:      rfile,
+		MetricsFile:    mfile,
+		DashboardsFile: dfile,
 	}
 	return &Config{
 		ExportConfig: expr,
-		MetricsExporterConfig: &internal.MetricsExporterConfig{
+		RulesExportConfig: &internal.RulesExportConfig{
 			ExportConfig: expr,
-			Since:        since,
+			GrafanaAddr:  c.String("grafana-addr"),
+			SvcToken:     token,
+		},
+		MetricsExporterConfig: &internal.MetricsExporterConfig{
+			ExportConfig:     expr,
+			Since:            since,
+			CardinalityLimit: c.Uint64("cardinality-limit"),
 		},
 		DashboardsExportConfig: &internal.DashboardsExportConfig{
 			ExportConfig: expr,
 			SvcToken:     token,
 		},
 		IdlerConfig: &internal.IdlerConfig{
-			RulesFile:      rfile,
-			MetricsFile:    mfile,
-			DashboardsFile: dfile,
-			Limit:          limit,
+			Source:   src,
+			VarsFile: vfile,
+			Live:     live,
+			Limit:    limit,
 		},
 		SlowestConfig: &internal.SlowestConfig{
-			RulesFile: rfile,
-			Limit:     limit,
+			Source: src,
+			Limit:  limit,
 		},
 		TopListerConfig: &internal.TopListerConfig{
-			DashboardsFile: dfile,
+			Source:   src,
+			VarsFile: vfile,
+			Limit:    limit,
+		},
+		FailingConfig: &internal.FailingConfig{
+			Source: src,
+			Limit:  limit,
+		},
+		GroupPressureConfig: &internal.GroupPressureConfig{
+			Source:    src,
+			Threshold: c.Float64("threshold"),
+			LagFactor: c.Float64("lag-factor"),
+			Limit:     limit,
+		},
+		AlertHistoryConfig: &internal.AlertHistoryConfig{
+			Source:       src,
+			Addr:         addr,
+			Since:        since,
+			Step:         c.Duration("step"),
+			Concurrency:  c.Int("concurrency"),
+			FlapDuration: c.Duration("flap-duration"),
+			Limit:        limit,
+		},
+		BacktestConfig: &internal.BacktestConfig{
+			Source:      src,
+			Addr:        addr,
+			Target:      c.Args().First(),
+			Since:       since,
+			Step:        c.Duration("step"),
+			Concurrency: c.Int("concurrency"),
+		},
+		LintConfig: &internal.LintConfig{
+			Source:         src,
+			VarsFile:       vfile,
+			ScrapeInterval: c.Duration("scrape-interval"),
+		},
+		NamingConfig: &internal.NamingConfig{
+			Source:   src,
+			VarsFile: vfile,
+		},
+		DuplicatesConfig: &internal.DuplicatesConfig{
+			Source: src,
+			Limit:  limit,
+		},
+		DashboardsRestoreConfig: &internal.DashboardsRestoreConfig{
+			Addr:        addr,
+			SvcToken:    token,
+			Dir:         c.String("dir"),
+			OrgID:       c.Int64("org-id"),
+			Policy:      c.String("policy"),
+			DryRun:      c.Bool("dry-run"),
+			Concurrency: c.Int("concurrency"),
+		},
+		DashboardsBackupConfig: &internal.DashboardsBackupConfig{
+			Addr:        addr,
+			SvcToken:    token,
+			Dir:         c.String("dir"),
+			Concurrency: c.Int("concurrency"),
+		},
+		StaleConfig: &internal.StaleConfig{
+			Source:   src,
+			VarsFile: vfile,
+			Days:     c.Uint("days"),
+			Limit:    limit,
+		},
+		StructureConfig: &internal.StructureConfig{
+			Source:           src,
+			MinSchemaVersion: c.Uint("min-schema-version"),
+			Limit:            limit,
+		},
+		LibraryPanelsConfig: &internal.LibraryPanelsConfig{
+			Source: src,
+			Limit:  limit,
+		},
+		PanelsConfig: &internal.PanelsConfig{
+			Source: src,
+			Limit:  limit,
+		},
+		LoadConfig: &internal.LoadConfig{
+			Source:           src,
+			MinRefresh:       c.Duration("min-refresh"),
+			LongRange:        c.Duration("long-range"),
+			MaxQueriesPerMin: c.Float64("max-queries-per-min"),
+			ScrapeInterval:   c.Duration("scrape-interval"),
+			Limit:            limit,
+		},
+		ProfileConfig: &internal.ProfileConfig{
+			Source:      src,
+			VarsFile:    vfile,
+			Addr:        addr,
+			Window:      c.String("window"),
+			Step:        c.Duration("step"),
+			Concurrency: c.Int("concurrency"),
+			Limit:       limit,
+		},
+		RecordingRewritesConfig: &internal.RecordingRewritesConfig{
+			Source:   src,
+			VarsFile: vfile,
+		},
+		SuggestRecordingsConfig: &internal.SuggestRecordingsConfig{
+			Source:         src,
+			VarsFile:       vfile,
+			Output:         out,
+			Group:          c.String("group"),
+			MinOccurrences: c.Uint64("min-occurrences"),
 			Limit:          limit,
 		},
+		AuditConfig: &internal.AuditConfig{
+			PromAddr:    c.String("prom-addr"),
+			GrafanaAddr: c.String("grafana-addr"),
+			SvcToken:    token,
+			Since:       since,
+			Snapshot:    snapshot,
+			SkipExport:  c.Bool("skip-export"),
+			Output:      out,
+			VarsFile:    vfile,
+			Limit:       limit,
+		},
 	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/eyazici90/owl/internal"
 	"github.com/urfave/cli/v2"
@@ -16,6 +17,10 @@ var rulesCmd = &cli.Command{
 			Usage:  `Exports prom rules to csv file`,
 			Action: actionRulesExport,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to export into instead of the csv output",
+				},
 				&cli.StringFlag{
 					Name:    "output",
 					Aliases: []string{"o"},
@@ -25,6 +30,14 @@ var rulesCmd = &cli.Command{
 					Name:     "addr",
 					Required: true,
 				},
+				&cli.StringFlag{
+					Name:  "grafana-addr",
+					Usage: "grafana to export the grafana managed rules of along with the prom ones",
+				},
+				&cli.StringFlag{
+					Name:  "svc-token",
+					Usage: "grafana service account token, required with grafana-addr",
+				},
 			},
 		},
 		{
@@ -32,6 +45,10 @@ var rulesCmd = &cli.Command{
 			Usage:  `Scans prom rules to find ones that are missing metrics`,
 			Action: actionRulesIdle,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
 				&cli.StringFlag{
 					Name:  "rules-file",
 					Value: "rules.csv",
@@ -40,6 +57,22 @@ var rulesCmd = &cli.Command{
 					Name:  "metrics-file",
 					Value: "metrics.csv",
 				},
+				&cli.StringFlag{
+					Name:  "vars-file",
+					Usage: "yaml file of grafana variable values used while parsing queries",
+				},
+				&cli.BoolFlag{
+					Name:  "live",
+					Usage: "validates selectors against prometheus to find ones matching no series",
+				},
+				&cli.StringFlag{
+					Name:  "addr",
+					Usage: "prometheus address, required in live mode",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
 				&cli.Uint64Flag{
 					Name:  "limit",
 					Value: 10,
@@ -51,6 +84,149 @@ var rulesCmd = &cli.Command{
 			Action: actionRulesSlowest,
 			Usage:  `Scans prom rules to find slowest ones based on evaluation durations`,
 			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "failing",
+			Action: actionRulesFailing,
+			Usage:  `Lists prom rules in error state grouped by their error`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "groups",
+			Action: actionRulesGroups,
+			Usage:  `Finds rule groups whose evaluation approaches their interval or lags behind`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.Float64Flag{
+					Name:  "threshold",
+					Usage: "ratio of evaluation time to interval from which on a group is under pressure",
+					Value: 0.8,
+				},
+				&cli.Float64Flag{
+					Name:  "lag-factor",
+					Usage: "number of intervals the last evaluation of a group may lag behind",
+					Value: 2,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:   "alert-history",
+			Action: actionRulesAlertHistory,
+			Usage:  `Ranks alerting rules by noise based on their ALERTS series`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "addr",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "30d",
+				},
+				&cli.DurationFlag{
+					Name:  "step",
+					Value: time.Minute,
+				},
+				&cli.DurationFlag{
+					Name:  "flap-duration",
+					Usage: "average firing duration under which an alert firing repeatedly is considered flapping",
+					Value: 10 * time.Minute,
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+				&cli.Uint64Flag{
+					Name:  "limit",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:      "backtest",
+			Action:    actionRulesBacktest,
+			Usage:     `Evaluates alerting rules over historical data & reports when they would have fired`,
+			ArgsUsage: "<name|file>",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "addr",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
+				&cli.StringFlag{
+					Name:  "rules-file",
+					Value: "rules.csv",
+				},
+				&cli.StringFlag{
+					Name:  "since",
+					Value: "7d",
+				},
+				&cli.DurationFlag{
+					Name:  "step",
+					Value: time.Minute,
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 4,
+				},
+			},
+		},
+		{
+			Name:   "duplicates",
+			Action: actionRulesDuplicates,
+			Usage:  `Finds rules with identical or equivalent queries & recording rules aliasing others`,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "snapshot directory to read from instead of the csv files",
+				},
 				&cli.StringFlag{
 					Name:  "rules-file",
 					Value: "rules.csv",
@@ -66,7 +242,10 @@ var rulesCmd = &cli.Command{
 
 func actionRulesExport(c *cli.Context) error {
 	cfg := actionSetup(c)
-	exp, err := internal.NewRulesExporter(cfg.ExportConfig)
+	if cfg.RulesExportConfig.GrafanaAddr != "" && cfg.RulesExportConfig.SvcToken == "" {
+		return fmt.Errorf("svc-token is required with grafana-addr")
+	}
+	exp, err := internal.NewRulesExporter(cfg.RulesExportConfig)
 	if err != nil {
 		return fmt.Errorf("new rules exporter: %w", err)
 	}
@@ -80,18 +259,34 @@ func actionRulesExport(c *cli.Context) error {
 
 func actionRulesIdle(c *cli.Context) error {
 	cfg := actionSetup(c)
+	if cfg.IdlerConfig.Live != nil && cfg.IdlerConfig.Live.Addr == "" {
+		return fmt.Errorf("addr is required in live mode")
+	}
 	pri := internal.NewPromRulesIdler(cfg.IdlerConfig)
 	res, err := pri.List(c.Context)
 	if err != nil {
 		return fmt.Errorf("list idle rules: %w", err)
 	}
-	for _, rule := range res {
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, rule := range res.Rules {
 		slog.Info("Found",
 			slog.String("item", fmt.Sprintf("%+v", rule)),
 		)
 	}
+	for _, es := range res.EmptySelectors {
+		slog.Info("Empty",
+			slog.String("group", es.Rule.Group),
+			slog.String("name", es.Rule.Name),
+			slog.Any("selectors", es.Selectors),
+		)
+	}
 	slog.Info("Found",
-		slog.Int("total", len(res)),
+		slog.Int("total", len(res.Rules)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+		slog.Int("empty-total", len(res.EmptySelectors)),
 	)
 	return nil
 }
@@ -117,3 +312,178 @@ func actionRulesSlowest(c *cli.Context) error {
 	)
 	return nil
 }
+
+func actionRulesFailing(c *cli.Context) error {
+	cfg := actionSetup(c)
+	prf := internal.NewPromRulesFailing(cfg.FailingConfig)
+	res, err := prf.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list failing: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	var total int
+	for _, fe := range res.Errors {
+		slog.Info("Failing",
+			slog.String("error", fe.Error),
+			slog.Int("count", len(fe.Rules)),
+		)
+		for _, rule := range fe.Rules {
+			slog.Info("Rule",
+				slog.String("group", rule.Group),
+				slog.String("name", rule.Name),
+				slog.String("query", rule.Query),
+			)
+		}
+		total += len(fe.Rules)
+	}
+	slog.Info("Found",
+		slog.Int("total", total),
+		slog.Int("errors", len(res.Errors)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionRulesGroups(c *cli.Context) error {
+	cfg := actionSetup(c)
+	pgp := internal.NewPromRuleGroupsPressure(cfg.GroupPressureConfig)
+	res, err := pgp.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list group pressure: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, gp := range res.Groups {
+		heaviest := make([]string, 0, len(gp.Heaviest))
+		for _, r := range gp.Heaviest {
+			heaviest = append(heaviest, r.Name)
+		}
+		slog.Info("Pressured",
+			slog.String("group", gp.Group),
+			slog.Any("issues", gp.Issues),
+			slog.Duration("interval", gp.Interval),
+			slog.Duration("eval-time", gp.EvalTime),
+			slog.Duration("lag", gp.Lag),
+			slog.String("ratio", fmt.Sprintf("%.2f", gp.Ratio)),
+			slog.Int("suggested-splits", gp.SuggestedSplits),
+			slog.Any("heaviest", heaviest),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Groups)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionRulesAlertHistory(c *cli.Context) error {
+	cfg := actionSetup(c)
+	pah := internal.NewPromAlertHistory(cfg.AlertHistoryConfig)
+	res, err := pah.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list alert history: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, ah := range res.Alerts {
+		slog.Info("Alert",
+			slog.String("name", ah.Rule.Name),
+			slog.String("group", ah.Rule.Group),
+			slog.Int("fires", ah.Fires),
+			slog.Duration("firing-time", ah.FiringTime),
+			slog.Duration("pending-time", ah.PendingTime),
+			slog.Int("transitions", ah.Transitions),
+			slog.Int("instances", ah.Instances),
+			slog.Int("active-before", ah.ActiveBefore),
+			slog.String("noise", ah.Noise),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Alerts)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionRulesBacktest(c *cli.Context) error {
+	cfg := actionSetup(c)
+	if c.NArg() != 1 {
+		return fmt.Errorf("expected 1 argument, got %d", c.NArg())
+	}
+	prb := internal.NewPromRuleBacktester(cfg.BacktestConfig)
+	res, err := prb.Run(c.Context)
+	if err != nil {
+		return fmt.Errorf("backtest: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, bt := range res.Backtests {
+		for _, fi := range bt.Intervals {
+			slog.Info("Fire",
+				slog.String("name", bt.Rule.Name),
+				slog.String("origin", bt.Origin),
+				slog.String("labels", fi.Labels.String()),
+				slog.Time("start", fi.Start),
+				slog.Time("end", fi.End),
+			)
+		}
+		slog.Info("Backtest",
+			slog.String("name", bt.Rule.Name),
+			slog.String("group", bt.Rule.Group),
+			slog.String("origin", bt.Origin),
+			slog.String("query", bt.Rule.Query),
+			slog.Duration("for", time.Duration(bt.Rule.For*float64(time.Second))),
+			slog.Int("fires", bt.Fires),
+			slog.Int("instances", bt.Instances),
+			slog.Duration("firing-time", bt.FiringTime),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Backtests)),
+		slog.Int("err-count", len(res.ParseErrs)),
+	)
+	return nil
+}
+
+func actionRulesDuplicates(c *cli.Context) error {
+	cfg := actionSetup(c)
+	prd := internal.NewPromRulesDuplicates(cfg.DuplicatesConfig)
+	res, err := prd.List(c.Context)
+	if err != nil {
+		return fmt.Errorf("list duplicates: %w", err)
+	}
+	for _, pe := range res.ParseErrs {
+		slog.Debug("Error", slog.Any("msg", pe))
+	}
+	for _, dup := range res.Duplicates {
+		rules := make([]string, 0, len(dup.Rules))
+		for _, r := range dup.Rules {
+			rules = append(rules, r.Group+"/"+r.Name)
+		}
+		slog.Info("Duplicate",
+			slog.String("query", dup.Query),
+			slog.Bool("identical", dup.Identical),
+			slog.String("recording", dup.Recording),
+			slog.Any("rules", rules),
+		)
+	}
+	for _, a := range res.Aliases {
+		slog.Info("Alias",
+			slog.String("group", a.Rule.Group),
+			slog.String("name", a.Rule.Name),
+			slog.String("of", a.Of),
+		)
+	}
+	slog.Info("Found",
+		slog.Int("total", len(res.Duplicates)),
+		slog.Int("aliases", len(res.Aliases)),
+		slog.Int("err-count", len(res.ParseErrs)),
+		slog.Int("failed-expr-count", internal.CountExprErrs(res.ParseErrs)),
+	)
+	return nil
+}
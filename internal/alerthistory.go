@@ -0,0 +1,265 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
+)
+
+type AlertHistoryConfig struct {
+	*Source
+	Addr string
+	// Since is the lookback window, e.g. 30d.
+	Since string
+	// Step is the resolution of the range queries, it's increased when the window would exceed the points limit.
+	Step        time.Duration
+	Concurrency int
+	// FlapDuration is the average firing duration under which an alert firing repeatedly is considered flapping.
+	FlapDuration time.Duration
+	Limit        uint64
+}
+
+const (
+	AlertNoiseNeverFired   = "never-fired"
+	AlertNoiseAlwaysFiring = "always-firing"
+	AlertNoiseFlapping     = "flapping"
+)
+
+type (
+	AlertHistoryResult struct {
+		Alerts    []AlertHistory
+		ParseErrs []error
+	}
+	AlertHistory struct {
+		Rule Rule
+		// Instances is the number of distinct label sets the alert was active with.
+		Instances int
+		// ActiveBefore is the number of instances that were already active when the window started.
+		ActiveBefore int
+		Fires        int
+		FiringTime   time.Duration
+		// PendingTime is the time the instances spent pending, waiting for the rule's for duration.
+		PendingTime time.Duration
+		Transitions int
+		Noise       string
+	}
+)
+
+type alertState uint8
+
+const (
+	alertInactive alertState = iota
+	alertPending
+	alertFiring
+)
+
+// maxQueryPoints is the number of points prometheus allows a range query to return per series.
+const maxQueryPoints = 11000
+
+type PromAlertHistory struct {
+	cfg   *AlertHistoryConfig
+	v1api promapiv1.API
+}
+
+func NewPromAlertHistory(cfg *AlertHistoryConfig) *PromAlertHistory {
+	return &PromAlertHistory{
+		cfg:   cfg,
+		v1api: mustNewPromAPIV1(cfg.Addr),
+	}
+}
+
+// List queries the ALERTS & ALERTS_FOR_STATE series of every alerting rule & ranks them by noise, the noisiest first.
+func (pah *PromAlertHistory) List(ctx context.Context) (*AlertHistoryResult, error) {
+	since, err := model.ParseDuration(pah.cfg.Since)
+	if err != nil {
+		return nil, fmt.Errorf("parse since: %w", err)
+	}
+	rules, silentErrs, err := pah.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	r := promapiv1.Range{
+		Start: end.Add(-time.Duration(since)),
+		End:   end,
+		Step:  max(pah.cfg.Step, time.Duration(since)/maxQueryPoints),
+	}
+
+	var (
+		mu   sync.Mutex
+		res  []AlertHistory
+		seen = make(map[string]struct{})
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(pah.cfg.Concurrency, 1))
+	for _, rule := range rules {
+		// grafana keeps the state of its rules itself, only prometheus writes ALERTS series
+		if rule.Type != "alert" || rule.IsGrafana() {
+			continue
+		}
+		if _, ok := seen[rule.Name]; ok {
+			continue
+		}
+		seen[rule.Name] = struct{}{}
+		eg.Go(func() error {
+			ah, err := pah.historyOf(egctx, rule, r)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("history of %q: %w", rule.Name, err))
+				return nil
+			}
+			res = append(res, *ah)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, fmt.Errorf("wait eg: %w", err)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Fires != res[j].Fires {
+			return res[i].Fires > res[j].Fires
+		}
+		if res[i].Transitions != res[j].Transitions {
+			return res[i].Transitions > res[j].Transitions
+		}
+		return res[i].FiringTime > res[j].FiringTime
+	})
+	return &AlertHistoryResult{
+		Alerts:    res[:min(pah.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+// historyOf queries the ALERTS & ALERTS_FOR_STATE series of the rule, see alertHistoryOf.
+func (pah *PromAlertHistory) historyOf(ctx context.Context, rule Rule, r promapiv1.Range) (*AlertHistory, error) {
+	alerts, err := pah.queryRange(ctx, fmt.Sprintf(`ALERTS{alertname=%q}`, rule.Name), r)
+	if err != nil {
+		return nil, err
+	}
+	forState, err := pah.queryRange(ctx, fmt.Sprintf(`ALERTS_FOR_STATE{alertname=%q}`, rule.Name), r)
+	if err != nil {
+		return nil, err
+	}
+	return alertHistoryOf(rule, alerts, forState, r, pah.cfg.FlapDuration), nil
+}
+
+func (pah *PromAlertHistory) queryRange(ctx context.Context, query string, r promapiv1.Range) (model.Matrix, error) {
+	val, _, err := pah.v1api.QueryRange(ctx, query, r)
+	if err != nil {
+		return nil, fmt.Errorf("query range %s: %w", query, err)
+	}
+	matrix, ok := val.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type: %s", val.Type())
+	}
+	return matrix, nil
+}
+
+// alertHistoryOf builds a timeline of the alert's states per instance out of its ALERTS series.
+// The ALERTS_FOR_STATE series hold the time the alerts became active, which marks the steps in between as pending
+// even when the step is too coarse to catch them & tells the instances that were active before the window.
+func alertHistoryOf(rule Rule, alerts, forState model.Matrix, r promapiv1.Range, flapDuration time.Duration) *AlertHistory {
+	steps := int(r.End.Sub(r.Start)/r.Step) + 1
+	stepOf := func(t time.Time) int {
+		return int((t.Sub(r.Start) + r.Step/2) / r.Step)
+	}
+	timelines := make(map[model.Fingerprint][]alertState)
+	timelineOf := func(m model.Metric) ([]alertState, model.Fingerprint) {
+		instance := m.Clone()
+		delete(instance, model.MetricNameLabel)
+		delete(instance, "alertstate")
+		fp := instance.Fingerprint()
+		tl, ok := timelines[fp]
+		if !ok {
+			tl = make([]alertState, steps)
+			timelines[fp] = tl
+		}
+		return tl, fp
+	}
+
+	for _, ss := range alerts {
+		state := alertPending
+		if ss.Metric["alertstate"] == "firing" {
+			state = alertFiring
+		}
+		tl, _ := timelineOf(ss.Metric)
+		for _, sample := range ss.Values {
+			i := stepOf(sample.Timestamp.Time())
+			if i >= 0 && i < steps && state > tl[i] {
+				tl[i] = state
+			}
+		}
+	}
+	activeBefore := make(map[model.Fingerprint]struct{})
+	for _, ss := range forState {
+		tl, fp := timelineOf(ss.Metric)
+		// samples are ordered, steps marked for an earlier sample aren't visited again
+		marked := -1
+		for _, sample := range ss.Values {
+			i := min(stepOf(sample.Timestamp.Time()), steps-1)
+			from := stepOf(time.Unix(int64(sample.Value), 0))
+			if from < 0 {
+				activeBefore[fp] = struct{}{}
+			}
+			for j := max(from, marked+1, 0); j <= i; j++ {
+				if tl[j] == alertInactive {
+					tl[j] = alertPending
+				}
+			}
+			marked = max(marked, i)
+		}
+	}
+
+	ah := &AlertHistory{
+		Rule:         rule,
+		Instances:    len(timelines),
+		ActiveBefore: len(activeBefore),
+	}
+	var firingSteps, pendingSteps, maxFiringSteps int
+	for _, tl := range timelines {
+		// an alert already firing at the start of the window is counted as a fire, not as a transition
+		prev, instanceFiring := tl[0], 0
+		if prev == alertFiring {
+			ah.Fires++
+		}
+		for _, st := range tl {
+			if st != prev {
+				ah.Transitions++
+				if st == alertFiring {
+					ah.Fires++
+				}
+			}
+			switch st {
+			case alertFiring:
+				instanceFiring++
+			case alertPending:
+				pendingSteps++
+			}
+			prev = st
+		}
+		firingSteps += instanceFiring
+		maxFiringSteps = max(maxFiringSteps, instanceFiring)
+	}
+	ah.FiringTime = time.Duration(firingSteps) * r.Step
+	ah.PendingTime = time.Duration(pendingSteps) * r.Step
+
+	const alwaysFiringRatio = 0.9
+	switch {
+	case ah.Fires == 0:
+		ah.Noise = AlertNoiseNeverFired
+	case float64(maxFiringSteps) >= alwaysFiringRatio*float64(steps):
+		ah.Noise = AlertNoiseAlwaysFiring
+	case ah.Fires > 1 && ah.FiringTime/time.Duration(ah.Fires) < flapDuration:
+		ah.Noise = AlertNoiseFlapping
+	}
+	return ah
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+func TestAlertHistoryOf(t *testing.T) {
+	start := time.Date(2024, 11, 9, 22, 0, 0, 0, time.UTC)
+	// 11 steps of a minute
+	r := promapiv1.Range{Start: start, End: start.Add(10 * time.Minute), Step: time.Minute}
+	instance := model.LabelSet{"alertname": "HighLatency", "instance": "a"}
+	at := func(step int) time.Time {
+		return start.Add(time.Duration(step) * time.Minute)
+	}
+	// alerts returns the ALERTS series of the instance in the state at the given steps
+	alerts := func(state string, steps ...int) *model.SampleStream {
+		metric := model.Metric{model.MetricNameLabel: "ALERTS", "alertstate": model.LabelValue(state)}
+		for k, v := range instance {
+			metric[k] = v
+		}
+		ss := &model.SampleStream{Metric: metric}
+		for _, s := range steps {
+			ss.Values = append(ss.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(at(s).UnixNano()), Value: 1})
+		}
+		return ss
+	}
+	// forState returns the ALERTS_FOR_STATE series of the instance, active since activeAt at the given steps
+	forState := func(activeAt time.Time, steps ...int) *model.SampleStream {
+		metric := model.Metric{model.MetricNameLabel: "ALERTS_FOR_STATE"}
+		for k, v := range instance {
+			metric[k] = v
+		}
+		ss := &model.SampleStream{Metric: metric}
+		for _, s := range steps {
+			ss.Values = append(ss.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(at(s).UnixNano()),
+				Value:     model.SampleValue(activeAt.Unix()),
+			})
+		}
+		return ss
+	}
+	all := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	tests := []struct {
+		name     string
+		alerts   model.Matrix
+		forState model.Matrix
+		want     AlertHistory
+	}{
+		{
+			name: "never fired",
+			want: AlertHistory{Noise: AlertNoiseNeverFired},
+		},
+		{
+			name:   "pending only",
+			alerts: model.Matrix{alerts("pending", 3, 4)},
+			forState: model.Matrix{
+				forState(at(3), 3, 4),
+			},
+			want: AlertHistory{Instances: 1, PendingTime: 2 * time.Minute, Transitions: 2, Noise: AlertNoiseNeverFired},
+		},
+		{
+			name:     "always firing",
+			alerts:   model.Matrix{alerts("firing", all...)},
+			forState: model.Matrix{forState(start.Add(-time.Hour), all...)},
+			want: AlertHistory{
+				Instances: 1, ActiveBefore: 1, Fires: 1, FiringTime: 11 * time.Minute, Noise: AlertNoiseAlwaysFiring,
+			},
+		},
+		{
+			// the step is too coarse to catch the pending states, the active times of ALERTS_FOR_STATE tell them
+			name:   "flapping",
+			alerts: model.Matrix{alerts("firing", 1, 2, 5, 6, 9)},
+			forState: model.Matrix{
+				forState(at(0), 1, 2),
+				forState(at(4), 5, 6),
+				forState(at(8), 9),
+			},
+			want: AlertHistory{
+				Instances: 1, Fires: 3, FiringTime: 5 * time.Minute, PendingTime: 3 * time.Minute,
+				Transitions: 8, Noise: AlertNoiseFlapping,
+			},
+		},
+		{
+			name:     "firing at window start",
+			alerts:   model.Matrix{alerts("firing", 0, 1, 2)},
+			forState: model.Matrix{forState(start.Add(-30*time.Minute), 0, 1, 2)},
+			want: AlertHistory{
+				Instances: 1, ActiveBefore: 1, Fires: 1, FiringTime: 3 * time.Minute, Transitions: 1,
+			},
+		},
+		{
+			name:     "pending at window start",
+			alerts:   model.Matrix{alerts("pending", 0), alerts("firing", 1, 2)},
+			forState: model.Matrix{forState(start.Add(-2*time.Minute), 0, 1, 2)},
+			want: AlertHistory{
+				Instances: 1, ActiveBefore: 1, Fires: 1, FiringTime: 2 * time.Minute, PendingTime: time.Minute,
+				Transitions: 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alertHistoryOf(Rule{Name: "HighLatency"}, tt.alerts, tt.forState, r, 10*time.Minute)
+			tt.want.Rule = Rule{Name: "HighLatency"}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
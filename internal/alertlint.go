@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/template"
+)
+
+type AlertLintConfig struct {
+	*Source
+	RequiredLabels []string
+	// AllowedValues restricts the values of labels, labels missing here may have any value.
+	AllowedValues       map[string][]string
+	RequiredAnnotations []string
+}
+
+// IDs of the alert hygiene checks.
+const (
+	LintAlertWithoutFor    = "alert-without-for"
+	LintMissingLabel       = "missing-label"
+	LintLabelValue         = "label-value-not-allowed"
+	LintMissingAnnotation  = "missing-annotation"
+	LintAnnotationTemplate = "invalid-annotation-template"
+)
+
+// alertTemplateDefs are the variables prometheus defines for annotation templates.
+const alertTemplateDefs = "{{$labels := .Labels}}{{$externalLabels := .ExternalLabels}}{{$externalURL := .ExternalURL}}{{$value := .Value}}"
+
+// grafanaTemplateDefs are the variables grafana defines for the annotation templates of its rules,
+// $values holds the result of every query & expression by ref id.
+const grafanaTemplateDefs = "{{$labels := .Labels}}{{$values := .Values}}{{$value := .Value}}"
+
+type AlertLinter struct {
+	cfg *AlertLintConfig
+}
+
+func NewAlertLinter(cfg *AlertLintConfig) *AlertLinter {
+	return &AlertLinter{cfg: cfg}
+}
+
+// Lint checks the labels, annotations & `for` durations of the exported alerting rules,
+// findings are sorted by severity.
+func (al *AlertLinter) Lint(ctx context.Context) (*LintResult, error) {
+	rules, silentErrs, err := al.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res  []LintFinding
+		seen = make(map[string]struct{})
+	)
+	for _, rule := range rules {
+		if rule.Type != "alert" {
+			continue
+		}
+		// grafana rules are exported once per query, their labels & annotations are the same for all
+		if rule.IsGrafana() {
+			key := strings.Join([]string{rule.Folder, rule.Group, rule.Name}, "/")
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+		for _, f := range al.lint(ctx, rule) {
+			f.Rule = &rule
+			res = append(res, f)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return lintSeverityRank[res[i].Severity] < lintSeverityRank[res[j].Severity]
+	})
+	return &LintResult{
+		Findings:  res,
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+func (al *AlertLinter) lint(ctx context.Context, rule Rule) []LintFinding {
+	var res []LintFinding
+	add := func(id, severity string, format string, args ...any) {
+		res = append(res, LintFinding{
+			ID:       id,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if rule.For <= 0 {
+		add(LintAlertWithoutFor, LintSeverityWarning, "alert fires on the first evaluation, a for duration rides out blips")
+	}
+	for _, name := range al.cfg.RequiredLabels {
+		if _, ok := rule.Labels[model.LabelName(name)]; !ok {
+			add(LintMissingLabel, LintSeverityError, "label %s is missing", name)
+		}
+	}
+	for _, name := range sortedKeys(al.cfg.AllowedValues) {
+		allowed := al.cfg.AllowedValues[name]
+		val, ok := rule.Labels[model.LabelName(name)]
+		if ok && !slices.Contains(allowed, string(val)) {
+			add(LintLabelValue, LintSeverityError, "%s=%q is not one of %s", name, val, strings.Join(allowed, ", "))
+		}
+	}
+	for _, name := range al.cfg.RequiredAnnotations {
+		if _, ok := rule.Annotations[model.LabelName(name)]; !ok {
+			add(LintMissingAnnotation, LintSeverityWarning, "annotation %s is missing", name)
+		}
+	}
+	names := make([]string, 0, len(rule.Annotations))
+	for name := range rule.Annotations {
+		names = append(names, string(name))
+	}
+	slices.Sort(names)
+	defs := alertTemplateDefs
+	if rule.IsGrafana() {
+		defs = grafanaTemplateDefs
+	}
+	for _, name := range names {
+		text := rule.Annotations[model.LabelName(name)]
+		tmpl := template.NewTemplateExpander(ctx, defs+string(text), "__alert_"+rule.Name, nil, 0, nil, nil, nil)
+		if err := tmpl.ParseTest(); err != nil {
+			add(LintAnnotationTemplate, LintSeverityError, "annotation %s: %v", name, err)
+		}
+	}
+	return res
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/url"
+	"os"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+//go:embed report.html
+var reportTmpl string
+
+type AuditConfig struct {
+	PromAddr, GrafanaAddr, SvcToken string
+	// Since is the lookback of the metrics export.
+	Since string
+	// Snapshot is the directory the exports are written into.
+	Snapshot string
+	// SkipExport analyses the snapshot as it is, without exporting into it first.
+	SkipExport bool
+	// Output is the html report file.
+	Output   string
+	VarsFile string
+	Limit    uint64
+}
+
+type (
+	// AuditReport holds the results of all analysers run over a fresh export.
+	AuditReport struct {
+		GeneratedAt      time.Time
+		PromAddr         string
+		GrafanaAddr      string
+		IdleMetrics      []MetricName
+		IdleRules        []RuleMissingMetrics
+		IdleDashboards   []IdleDashboard
+		SlowestRules     []SlowRule
+		TopUsed          []MetricUsageInBoard
+		ParseErrs        []error
+		FailedExprsCount int
+	}
+)
+
+type Auditor struct {
+	cfg *AuditConfig
+}
+
+func NewAuditor(cfg *AuditConfig) *Auditor {
+	return &Auditor{cfg: cfg}
+}
+
+// Run exports everything into the snapshot, runs the analysers & writes the html report.
+func (a *Auditor) Run(ctx context.Context) (*AuditReport, error) {
+	if !a.cfg.SkipExport {
+		if err := a.export(ctx); err != nil {
+			return nil, err
+		}
+	}
+	rep, err := a.analyse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeAuditReport(a.cfg.Output, rep); err != nil {
+		return nil, err
+	}
+	return rep, nil
+}
+
+func (a *Auditor) export(ctx context.Context) error {
+	prom := &ExportConfig{Addr: a.cfg.PromAddr, Snapshot: a.cfg.Snapshot}
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		exp, err := NewMetricsExporter(&MetricsExporterConfig{ExportConfig: prom, Since: a.cfg.Since})
+		if err != nil {
+			return err
+		}
+		if err = exp.Export(egctx); err != nil {
+			return fmt.Errorf("export metrics: %w", err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		exp, err := NewRulesExporter(&RulesExportConfig{
+			ExportConfig: prom,
+			GrafanaAddr:  a.cfg.GrafanaAddr,
+			SvcToken:     a.cfg.SvcToken,
+		})
+		if err != nil {
+			return err
+		}
+		if err = exp.Export(egctx); err != nil {
+			return fmt.Errorf("export rules: %w", err)
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		exp, err := NewDashboardsExporter(&DashboardsExportConfig{
+			ExportConfig: &ExportConfig{Addr: a.cfg.GrafanaAddr, Snapshot: a.cfg.Snapshot},
+			SvcToken:     a.cfg.SvcToken,
+		})
+		if err != nil {
+			return err
+		}
+		res, err := exp.Export(egctx)
+		if err != nil {
+			return fmt.Errorf("export dashboards: %w", err)
+		}
+		slog.DebugContext(egctx, "Exported dashboards",
+			slog.Int("total", res.Total),
+			slog.Int("err-count", len(res.ParseErrs)),
+		)
+		return nil
+	})
+	return eg.Wait()
+}
+
+func (a *Auditor) analyse(ctx context.Context) (*AuditReport, error) {
+	src := &Source{Snapshot: a.cfg.Snapshot}
+	idler := &IdlerConfig{Source: src, VarsFile: a.cfg.VarsFile, Limit: a.cfg.Limit}
+	rep := &AuditReport{
+		GeneratedAt: time.Now().UTC(),
+		PromAddr:    a.cfg.PromAddr,
+		GrafanaAddr: a.cfg.GrafanaAddr,
+	}
+
+	metrics, err := NewMetricsIdler(idler).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list idle metrics: %w", err)
+	}
+	rep.IdleMetrics = metrics.IdleMetrics
+	rep.ParseErrs = append(rep.ParseErrs, metrics.ParseErrs...)
+
+	rules, err := NewPromRulesIdler(idler).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list idle rules: %w", err)
+	}
+	rep.IdleRules = rules.Rules
+	rep.ParseErrs = append(rep.ParseErrs, rules.ParseErrs...)
+
+	boards, err := NewDashboardsIdler(idler).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list idle dashboards: %w", err)
+	}
+	rep.IdleDashboards = boards.IdleDashboards
+	rep.ParseErrs = append(rep.ParseErrs, boards.ParseErrs...)
+
+	slowest, err := NewPromRulesSlowest(&SlowestConfig{Source: src, Limit: a.cfg.Limit}).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get slowest: %w", err)
+	}
+	rep.SlowestRules = slowest.Rules
+	rep.ParseErrs = append(rep.ParseErrs, slowest.ParseErrs...)
+
+	top, err := NewTopUsedListerInGrafana(&TopListerConfig{Source: src, VarsFile: a.cfg.VarsFile, Limit: a.cfg.Limit}).List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list top: %w", err)
+	}
+	rep.TopUsed = top.Usages
+	rep.ParseErrs = append(rep.ParseErrs, top.ParseErrs...)
+
+	rep.FailedExprsCount = CountExprErrs(rep.ParseErrs)
+	return rep, nil
+}
+
+func writeAuditReport(file string, rep *AuditReport) error {
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"boardURL": func(uid string) string {
+			scheme, host := grafanaSchemeHost(rep.GrafanaAddr, "https")
+			return (&url.URL{Scheme: scheme, Host: host, Path: "/d/" + uid}).String()
+		},
+		"exprURL": func(expr string) string {
+			return rep.PromAddr + "/graph?" + url.Values{"g0.expr": {expr}}.Encode()
+		},
+	}).Parse(reportTmpl)
+	if err != nil {
+		return fmt.Errorf("parse report template: %w", err)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("create report file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err = tmpl.Execute(f, rep); err != nil {
+		return fmt.Errorf("execute report template: %w", err)
+	}
+	return nil
+}
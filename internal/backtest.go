@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"golang.org/x/sync/errgroup"
+)
+
+type BacktestConfig struct {
+	*Source
+	Addr string
+	// Target is either the name of an exported alerting rule or a prometheus rule file.
+	Target string
+	// Since is the lookback window, e.g. 7d.
+	Since string
+	// Step is the resolution of the range queries, it's increased when the window would exceed the points limit.
+	Step        time.Duration
+	Concurrency int
+}
+
+// BacktestOriginExport marks backtests of rules taken from the export.
+const BacktestOriginExport = "export"
+
+type (
+	BacktestResult struct {
+		Backtests []Backtest
+		ParseErrs []error
+	}
+	Backtest struct {
+		Rule Rule
+		// Origin is either BacktestOriginExport or the rule file the rule was read from.
+		Origin string
+		// Instances is the number of distinct label sets that would have fired.
+		Instances  int
+		Fires      int
+		FiringTime time.Duration
+		Intervals  []FiringInterval
+	}
+	// FiringInterval is a period an alert instance would have been firing, both ends inclusive.
+	FiringInterval struct {
+		Labels     model.Metric
+		Start, End time.Time
+	}
+)
+
+type PromRuleBacktester struct {
+	cfg   *BacktestConfig
+	v1api promapiv1.API
+}
+
+func NewPromRuleBacktester(cfg *BacktestConfig) *PromRuleBacktester {
+	return &PromRuleBacktester{
+		cfg:   cfg,
+		v1api: mustNewPromAPIV1(cfg.Addr),
+	}
+}
+
+// Run evaluates the expressions of the target's alerting rules over the lookback window.
+// When the target is a rule file, exported rules of the same names are backtested as well,
+// so that the current & the proposed expressions can be compared.
+func (prb *PromRuleBacktester) Run(ctx context.Context) (*BacktestResult, error) {
+	since, err := model.ParseDuration(prb.cfg.Since)
+	if err != nil {
+		return nil, fmt.Errorf("parse since: %w", err)
+	}
+	rules, silentErrs, err := prb.targetRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	r := promapiv1.Range{
+		Start: end.Add(-time.Duration(since)),
+		End:   end,
+		Step:  max(prb.cfg.Step, time.Duration(since)/maxQueryPoints),
+	}
+
+	var (
+		mu  sync.Mutex
+		res []Backtest
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(prb.cfg.Concurrency, 1))
+	for _, bt := range rules {
+		eg.Go(func() error {
+			err := prb.backtest(egctx, &bt, r)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("backtest %q of %s: %w", bt.Rule.Name, bt.Origin, err))
+				return nil
+			}
+			res = append(res, bt)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, fmt.Errorf("wait eg: %w", err)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Rule.Name != res[j].Rule.Name {
+			return res[i].Rule.Name < res[j].Rule.Name
+		}
+		if res[i].Origin != res[j].Origin {
+			return res[i].Origin == BacktestOriginExport
+		}
+		return res[i].Rule.Group < res[j].Rule.Group
+	})
+	return &BacktestResult{
+		Backtests: res,
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+func (prb *PromRuleBacktester) targetRules(ctx context.Context) ([]Backtest, []error, error) {
+	if fi, err := os.Stat(prb.cfg.Target); err != nil || fi.IsDir() {
+		exported, silentErrs, err := prb.cfg.readRules(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		res := exportedAlerts(exported, map[string]struct{}{prb.cfg.Target: {}})
+		if len(res) == 0 {
+			return nil, nil, fmt.Errorf("no alerting rule named %q", prb.cfg.Target)
+		}
+		return res, silentErrs, nil
+	}
+
+	groups, errs := rulefmt.ParseFile(prb.cfg.Target)
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("parse rule file: %w", errors.Join(errs...))
+	}
+	var (
+		res   []Backtest
+		names = make(map[string]struct{})
+	)
+	for _, g := range groups.Groups {
+		for _, rn := range g.Rules {
+			if rn.Alert.Value == "" {
+				continue
+			}
+			labels := make(model.LabelSet, len(rn.Labels))
+			for k, v := range rn.Labels {
+				labels[model.LabelName(k)] = model.LabelValue(v)
+			}
+			res = append(res, Backtest{
+				Rule: Rule{
+					Group:  g.Name,
+					Type:   "alert",
+					Name:   rn.Alert.Value,
+					Query:  rn.Expr.Value,
+					Labels: labels,
+					For:    time.Duration(rn.For).Seconds(),
+				},
+				Origin: prb.cfg.Target,
+			})
+			names[rn.Alert.Value] = struct{}{}
+		}
+	}
+	if len(res) == 0 {
+		return nil, nil, fmt.Errorf("no alerting rules in %s", prb.cfg.Target)
+	}
+
+	// the export is optional while backtesting a rule file
+	exported, silentErrs, err := prb.cfg.readRules(ctx)
+	if err != nil {
+		return res, []error{fmt.Errorf("read exported rules: %w", err)}, nil
+	}
+	return append(res, exportedAlerts(exported, names)...), silentErrs, nil
+}
+
+func exportedAlerts(rules []Rule, names map[string]struct{}) []Backtest {
+	var res []Backtest
+	for _, rule := range rules {
+		// grafana rules are exported as their raw queries, without the condition deciding when they fire
+		if rule.Type != "alert" || rule.IsGrafana() {
+			continue
+		}
+		if _, ok := names[rule.Name]; ok {
+			res = append(res, Backtest{Rule: rule, Origin: BacktestOriginExport})
+		}
+	}
+	return res
+}
+
+// backtest runs the rule's expression as range query. Every series of the result is an alert instance,
+// which fires once it's been returned for the rule's `for` duration without a gap.
+// Instances already pending at the start of the window are considered pending since then.
+func (prb *PromRuleBacktester) backtest(ctx context.Context, bt *Backtest, r promapiv1.Range) error {
+	val, _, err := prb.v1api.QueryRange(ctx, bt.Rule.Query, r)
+	if err != nil {
+		return fmt.Errorf("query range: %w", err)
+	}
+	matrix, ok := val.(model.Matrix)
+	if !ok {
+		return fmt.Errorf("unexpected result type: %s", val.Type())
+	}
+
+	forDuration := secondsToDuration(bt.Rule.For)
+	for _, ss := range matrix {
+		var (
+			fired                  bool
+			runStart, prev, firing time.Time
+		)
+		flush := func() {
+			if firing.IsZero() {
+				return
+			}
+			bt.Intervals = append(bt.Intervals, FiringInterval{Labels: ss.Metric, Start: firing, End: prev})
+			bt.Fires++
+			bt.FiringTime += prev.Sub(firing) + r.Step
+			fired = true
+		}
+		for _, sample := range ss.Values {
+			ts := sample.Timestamp.Time()
+			if runStart.IsZero() || ts.Sub(prev) > r.Step {
+				flush()
+				runStart, firing = ts, time.Time{}
+			}
+			if firing.IsZero() && ts.Sub(runStart) >= forDuration {
+				firing = ts
+			}
+			prev = ts
+		}
+		flush()
+		if fired {
+			bt.Instances++
+		}
+	}
+	sort.Slice(bt.Intervals, func(i, j int) bool {
+		return bt.Intervals[i].Start.Before(bt.Intervals[j].Start)
+	})
+	return nil
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+func TestPromRuleBacktesterFor(t *testing.T) {
+	start := time.Date(2024, 11, 9, 22, 0, 0, 0, time.UTC)
+	at := func(step int) time.Time {
+		return start.Add(time.Duration(step) * time.Minute)
+	}
+	values := func(steps ...int) string {
+		vals := make([]string, 0, len(steps))
+		for _, s := range steps {
+			vals = append(vals, fmt.Sprintf(`[%d,"1"]`, at(s).Unix()))
+		}
+		return "[" + strings.Join(vals, ",") + "]"
+	}
+	// instance a is returned for 5 steps, then for 2 steps after a gap, instance b for 2 steps
+	body := fmt.Sprintf(`{"status":"success","data":{"resultType":"matrix","result":[`+
+		`{"metric":{"instance":"a"},"values":%s},`+
+		`{"metric":{"instance":"b"},"values":%s}]}}`, values(0, 1, 2, 3, 4, 7, 8), values(1, 2))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	prb := NewPromRuleBacktester(&BacktestConfig{Addr: srv.URL})
+	r := promapiv1.Range{Start: start, End: at(10), Step: time.Minute}
+	tests := []struct {
+		name       string
+		forSeconds float64
+		fires      int
+		instances  int
+		firingTime time.Duration
+		intervals  [][2]int
+	}{
+		{
+			name:  "fires at once without for",
+			fires: 3, instances: 2, firingTime: 9 * time.Minute,
+			intervals: [][2]int{{0, 4}, {1, 2}, {7, 8}},
+		},
+		{
+			// a is pending for 2 steps before firing, its second run & b never last long enough
+			name: "pending until for passed", forSeconds: 120,
+			fires: 1, instances: 1, firingTime: 3 * time.Minute,
+			intervals: [][2]int{{2, 4}},
+		},
+		{
+			name: "never fires with a longer for", forSeconds: 600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bt := &Backtest{Rule: Rule{Type: "alert", Name: "HighLatency", Query: "latency > 1", For: tt.forSeconds}}
+			if err := prb.backtest(context.Background(), bt, r); err != nil {
+				t.Fatal(err)
+			}
+			if bt.Fires != tt.fires || bt.Instances != tt.instances || bt.FiringTime != tt.firingTime {
+				t.Errorf("got fires=%d instances=%d firing-time=%s, want fires=%d instances=%d firing-time=%s",
+					bt.Fires, bt.Instances, bt.FiringTime, tt.fires, tt.instances, tt.firingTime)
+			}
+			var got [][2]int
+			for _, iv := range bt.Intervals {
+				got = append(got, [2]int{int(iv.Start.Sub(start) / time.Minute), int(iv.End.Sub(start) / time.Minute)})
+			}
+			if !reflect.DeepEqual(got, tt.intervals) {
+				t.Errorf("got intervals %v, want %v", got, tt.intervals)
+			}
+		})
+	}
+}
+
+func TestPromRuleBacktesterRuleNotFound(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.csv")
+	rules := []Rule{
+		{Group: "api", Type: "alert", Name: "HighLatency", Query: "latency > 1", Source: RuleSourcePrometheus},
+		{Group: "api", Type: "record", Name: "Missing", Query: "sum(latency)", Source: RuleSourcePrometheus},
+		// grafana rules can't be backtested, they're as good as missing
+		{Group: "api", Type: "alert", Name: "Missing", Query: "latency", Source: RuleSourceGrafana, Folder: "api", RefID: "A"},
+	}
+	if err := writeAllRulesCSV(context.Background(), file, rules); err != nil {
+		t.Fatal(err)
+	}
+	prb := NewPromRuleBacktester(&BacktestConfig{
+		Source: &Source{RulesFile: file},
+		Addr:   "http://localhost:9090",
+		Target: "Missing",
+		Since:  "1h",
+	})
+	_, err := prb.Run(context.Background())
+	if err == nil || err.Error() != `no alerting rule named "Missing"` {
+		t.Errorf("got error %v, want no alerting rule named \"Missing\"", err)
+	}
+}
@@ -0,0 +1,286 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/runtime"
+	goapi "github.com/grafana/grafana-openapi-client-go/client"
+	"github.com/grafana/grafana-openapi-client-go/models"
+	"golang.org/x/sync/errgroup"
+)
+
+type DashboardsBackupConfig struct {
+	Addr     string
+	SvcToken string
+	// Dir is the directory the dashboards are written into, one directory per folder.
+	Dir         string
+	Concurrency int
+}
+
+// backupGeneralFolder is the directory of dashboards that aren't in any folder.
+const backupGeneralFolder = "general"
+
+// backupFoldersFile lists the folders of the backup along with their parents, so that nested folders are restored in place.
+const backupFoldersFile = "folders.json"
+
+type (
+	BackupResult struct {
+		Total int
+		// Written are the dashboards that are new or whose version changed since the last backup.
+		Written   []BackupEntry
+		Unchanged int
+		ParseErrs []error
+	}
+	BackupEntry struct {
+		UID       string
+		Title     string
+		Folder    string
+		FolderUID string
+		Version   int64
+		Path      string
+	}
+	// backupFolder is a folder as listed in the folders file, ParentUID is empty for top level folders.
+	backupFolder struct {
+		UID       string `json:"uid"`
+		Title     string `json:"title"`
+		ParentUID string `json:"parentUid,omitempty"`
+	}
+)
+
+// backupFile is the layout of backed up dashboards, the same as grafana's dashboard by uid response.
+type backupFile = models.DashboardFullWithMeta
+
+type DashboardsBackuper struct {
+	cfg     *DashboardsBackupConfig
+	grafana *goapi.GrafanaHTTPAPI
+}
+
+func NewDashboardsBackuper(cfg *DashboardsBackupConfig) *DashboardsBackuper {
+	return &DashboardsBackuper{
+		cfg: cfg,
+		grafana: newGrafanaOAPI(&GrafanaConfig{
+			URL:    cfg.Addr,
+			Scheme: "https",
+			APIKey: cfg.SvcToken,
+		}),
+	}
+}
+
+// Backup writes the full json model & meta of every dashboard into <dir>/<folder uid>/<uid>.json.
+// Dashboards whose version & folder are the same as in the directory are skipped,
+// the backups of moved dashboards are moved along. The folders & their parents are listed in <dir>/folders.json.
+func (db *DashboardsBackuper) Backup(ctx context.Context) (*BackupResult, error) {
+	if err := os.MkdirAll(db.cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create backup dir: %w", err)
+	}
+	existing, silentErrs, err := readBackupIndex(db.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	uids, err := getAllDashboards(ctx, db.grafana)
+	if err != nil {
+		return nil, fmt.Errorf("get all dashboards: %w", err)
+	}
+
+	// backups of dashboards failing or deleted since stay in the directory, so do their folders
+	folderUIDs := make(map[string]struct{})
+	for _, prev := range existing {
+		folderUIDs[prev.FolderUID] = struct{}{}
+	}
+	var (
+		mu  sync.Mutex
+		res = &BackupResult{Total: len(uids)}
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(db.cfg.Concurrency, 1))
+	for _, uid := range uids {
+		eg.Go(func() error {
+			entry, written, err := db.backup(egctx, uid, existing[uid])
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				folderUIDs[entry.FolderUID] = struct{}{}
+			}
+			switch {
+			case err != nil:
+				silentErrs = append(silentErrs, fmt.Errorf("backup %q: %w", uid, err))
+			case written:
+				res.Written = append(res.Written, *entry)
+			default:
+				res.Unchanged++
+			}
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, fmt.Errorf("wait eg: %w", err)
+	}
+	delete(folderUIDs, "")
+	silentErrs = append(silentErrs, db.backupFolders(ctx, folderUIDs)...)
+	res.ParseErrs = silentErrs
+	return res, nil
+}
+
+// backupFolders writes the folders file, folders grafana fails to return are kept as listed by the previous backup.
+func (db *DashboardsBackuper) backupFolders(ctx context.Context, uids map[string]struct{}) []error {
+	prev, err := readBackupFolders(db.cfg.Dir)
+	if err != nil {
+		return []error{err}
+	}
+	var (
+		silentErrs []error
+		folders    = make(map[string]backupFolder)
+	)
+	for uid := range uids {
+		resp, err := db.grafana.Folders.GetFolderByUID(uid, func(op *runtime.ClientOperation) {
+			op.Context = ctx
+		})
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("get folder %q: %w", uid, err))
+			for f, ok := prev[uid]; ok; f, ok = prev[f.ParentUID] {
+				if _, seen := folders[f.UID]; seen {
+					break
+				}
+				folders[f.UID] = f
+			}
+			continue
+		}
+		folders[uid] = backupFolder{UID: uid, Title: resp.Payload.Title, ParentUID: resp.Payload.ParentUID}
+		for _, p := range resp.Payload.Parents {
+			folders[p.UID] = backupFolder{UID: p.UID, Title: p.Title, ParentUID: p.ParentUID}
+		}
+	}
+
+	list := slices.SortedFunc(maps.Values(folders), func(a, b backupFolder) int {
+		return strings.Compare(a.UID, b.UID)
+	})
+	bs, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return append(silentErrs, fmt.Errorf("marshal folders: %w", err))
+	}
+	if err = os.WriteFile(filepath.Join(db.cfg.Dir, backupFoldersFile), bs, 0o644); err != nil {
+		return append(silentErrs, fmt.Errorf("write folders: %w", err))
+	}
+	return silentErrs
+}
+
+// readBackupFolders returns the folders listed in the backup directory by uid, backups of older versions list none.
+func readBackupFolders(dir string) (map[string]backupFolder, error) {
+	res := make(map[string]backupFolder)
+	bs, err := os.ReadFile(filepath.Join(dir, backupFoldersFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read folders: %w", err)
+	}
+	var list []backupFolder
+	if err = json.Unmarshal(bs, &list); err != nil {
+		return nil, fmt.Errorf("unmarshal folders: %w", err)
+	}
+	for _, f := range list {
+		res[f.UID] = f
+	}
+	return res, nil
+}
+
+func (db *DashboardsBackuper) backup(ctx context.Context, uid string, prev *BackupEntry) (*BackupEntry, bool, error) {
+	payload, err := getDashboardModelByUID(ctx, db.grafana, uid)
+	if err != nil {
+		return nil, false, err
+	}
+	entry := backupEntryOf(payload)
+	entry.UID = uid
+	entry.Path = filepath.Join(db.cfg.Dir, backupFolderDir(payload), uid+".json")
+	if prev != nil && prev.Version == entry.Version && prev.Path == entry.Path {
+		return &entry, false, nil
+	}
+
+	bs, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal dashboard: %w", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(entry.Path), 0o755); err != nil {
+		return nil, false, fmt.Errorf("create folder dir: %w", err)
+	}
+	if err = os.WriteFile(entry.Path, bs, 0o644); err != nil {
+		return nil, false, fmt.Errorf("write dashboard: %w", err)
+	}
+	if prev != nil && prev.Path != entry.Path {
+		if err = os.Remove(prev.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, fmt.Errorf("remove moved dashboard: %w", err)
+		}
+	}
+	return &entry, true, nil
+}
+
+func backupEntryOf(f *backupFile) BackupEntry {
+	var entry BackupEntry
+	if raw, ok := f.Dashboard.(map[string]any); ok {
+		entry.UID, _ = raw["uid"].(string)
+		entry.Title, _ = raw["title"].(string)
+	}
+	if f.Meta != nil {
+		entry.Folder, entry.FolderUID, entry.Version = f.Meta.FolderTitle, f.Meta.FolderUID, f.Meta.Version
+	}
+	return entry
+}
+
+func backupFolderDir(f *backupFile) string {
+	if f.Meta == nil || f.Meta.FolderUID == "" {
+		return backupGeneralFolder
+	}
+	return f.Meta.FolderUID
+}
+
+// readBackupIndex returns the dashboards already backed up in the directory by uid.
+func readBackupIndex(dir string) (map[string]*BackupEntry, []error, error) {
+	res := make(map[string]*BackupEntry)
+	silentErrs, err := walkBackups(dir, func(path string, f *backupFile) {
+		entry := backupEntryOf(f)
+		entry.Path = path
+		res[entry.UID] = &entry
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res, silentErrs, nil
+}
+
+// walkBackups calls fn with every dashboard backed up in the directory, backups that can't be decoded are silent errors.
+func walkBackups(dir string, fn func(path string, f *backupFile)) ([]error, error) {
+	var silentErrs []error
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") || path == filepath.Join(dir, backupFoldersFile) {
+			return nil
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read backup: %w", err)
+		}
+		var f backupFile
+		if err = json.Unmarshal(bs, &f); err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("unmarshal backup %s: %w", path, err))
+			return nil
+		}
+		fn(path, &f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk backup dir: %w", err)
+	}
+	return silentErrs, nil
+}
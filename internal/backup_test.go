@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDashboardsBackuper(t *testing.T) {
+	ctx := context.Background()
+	fg := newFakeGrafana()
+	fg.addFolder("ops", "Ops", "")
+	fg.addDashboard("ops", map[string]any{"uid": "a", "title": "A", "version": 1})
+	fg.addDashboard("", map[string]any{"uid": "b", "title": "B", "version": 2})
+	dir := t.TempDir()
+	backuper := NewDashboardsBackuper(&DashboardsBackupConfig{Addr: fg.start(t), Dir: dir})
+	backup := func(wantWritten ...string) {
+		t.Helper()
+		res, err := backuper.Backup(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.ParseErrs) > 0 {
+			t.Fatalf("backup errors: %v", res.ParseErrs)
+		}
+		var written []string
+		for _, be := range res.Written {
+			written = append(written, be.UID)
+		}
+		// backups run concurrently
+		sort.Strings(written)
+		if strings.Join(written, ",") != strings.Join(wantWritten, ",") || res.Unchanged != res.Total-len(wantWritten) {
+			t.Errorf("got written %v & %d unchanged, want written %v", written, res.Unchanged, wantWritten)
+		}
+	}
+	exists := func(path string, want bool) {
+		t.Helper()
+		_, err := os.Stat(filepath.Join(dir, path))
+		if (err == nil) != want {
+			t.Errorf("got %s existing %v, want %v", path, err == nil, want)
+		}
+	}
+
+	backup("a", "b")
+	exists("ops/a.json", true)
+	exists("general/b.json", true)
+
+	// dashboards of the same version aren't written again
+	tampered := filepath.Join(dir, "ops", "a.json")
+	bs, err := os.ReadFile(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs = []byte(strings.Replace(string(bs), `"title": "A"`, `"title": "tampered"`, 1))
+	if err = os.WriteFile(tampered, bs, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	backup()
+	if bs, _ = os.ReadFile(tampered); !strings.Contains(string(bs), "tampered") {
+		t.Error("got the unchanged dashboard written again")
+	}
+
+	fg.addDashboard("ops", map[string]any{"uid": "a", "title": "A", "version": 2})
+	backup("a")
+	if bs, _ = os.ReadFile(tampered); strings.Contains(string(bs), "tampered") {
+		t.Error("got the new version of the dashboard not written")
+	}
+
+	// moved dashboards are moved along, even without a new version
+	fg.addDashboard("ops", map[string]any{"uid": "b", "title": "B", "version": 2})
+	backup("b")
+	exists("general/b.json", false)
+	exists("ops/b.json", true)
+
+	folders, err := readBackupFolders(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folders) != 1 || folders["ops"].Title != "Ops" {
+		t.Errorf("got folders %+v, want ops", folders)
+	}
+}
@@ -7,40 +7,112 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"time"
 )
 
 type (
 	// Board represents Grafana dashboard.
 	Board struct {
-		ID     uint     `mapstructure:"id,omitempty"`
-		UID    string   `mapstructure:"uid,omitempty"`
-		Title  string   `mapstructure:"title"`
-		Tags   []string `mapstructure:"tags"`
-		Panels []*Panel `mapstructure:"panels"`
+		ID         uint       `mapstructure:"id,omitempty" json:"id,omitempty"`
+		UID        string     `mapstructure:"uid,omitempty" json:"uid,omitempty"`
+		Title      string     `mapstructure:"title" json:"title"`
+		Tags       []string   `mapstructure:"tags" json:"tags,omitempty"`
+		Panels     []*Panel   `mapstructure:"panels" json:"panels"`
+		Templating Templating `mapstructure:"templating" json:"templating"`
+		// Refresh is the auto refresh interval, e.g. 30s, empty when disabled.
+		Refresh string    `mapstructure:"refresh" json:"refresh,omitempty"`
+		Time    TimeRange `mapstructure:"time" json:"time"`
+		// Folder & FolderUID are taken from the dashboard's meta, empty for the general folder.
+		Folder        string `mapstructure:"-" json:"folder,omitempty"`
+		FolderUID     string `mapstructure:"-" json:"folderUid,omitempty"`
+		SchemaVersion uint   `mapstructure:"schemaVersion" json:"schemaVersion,omitempty"`
+		// Updated & Version, the number of saves, are taken from the dashboard's meta.
+		Updated time.Time `mapstructure:"-" json:"updated"`
+		Version int64     `mapstructure:"-" json:"version,omitempty"`
+		// Views is the number of views of the last 30 days, nil unless grafana has usage insights.
+		Views *int64 `mapstructure:"-" json:"views,omitempty"`
 	}
 	Panel struct {
-		ID      uint      `mapstructure:"id"`
-		OfType  panelType `mapstructure:"-"`     // it required for defining type of the panel
-		Title   string    `mapstructure:"title"` // general
-		Type    string    `mapstructure:"type"`
-		Targets []Target  `mapstructure:"targets,omitempty"`
+		ID      uint      `mapstructure:"id" json:"id"`
+		OfType  panelType `mapstructure:"-" json:"-"`         // it required for defining type of the panel
+		Title   string    `mapstructure:"title" json:"title"` // general
+		Type    string    `mapstructure:"type" json:"type"`
+		Targets []Target  `mapstructure:"targets,omitempty" json:"targets,omitempty"`
+		// Interval is the lower limit of the query step, e.g. 1m.
+		Interval      string `mapstructure:"interval,omitempty" json:"interval,omitempty"`
+		MaxDataPoints uint   `mapstructure:"maxDataPoints,omitempty" json:"maxDataPoints,omitempty"`
+		// Panels are the panels of a collapsed row.
+		Panels []*Panel `mapstructure:"panels,omitempty" json:"panels,omitempty"`
+		// LibraryPanel references the library panel the panel is an instance of, whose model the export inlines.
+		LibraryPanel *LibraryPanelRef `mapstructure:"libraryPanel,omitempty" json:"libraryPanel,omitempty"`
+	}
+	LibraryPanelRef struct {
+		UID  string `mapstructure:"uid" json:"uid"`
+		Name string `mapstructure:"name" json:"name,omitempty"`
 	}
 	Target struct {
-		Datasource any    `mapstructure:"datasource,omitempty"`
-		Expr       string `mapstructure:"expr,omitempty"`
+		Datasource any    `mapstructure:"datasource,omitempty" json:"datasource,omitempty"`
+		Expr       string `mapstructure:"expr,omitempty" json:"expr,omitempty"`
+		RefID      string `mapstructure:"refId,omitempty" json:"refId,omitempty"`
+		// Hide is set for queries disabled in the panel editor.
+		Hide bool `mapstructure:"hide,omitempty" json:"hide,omitempty"`
 	}
 	panelType int8
+
+	// TimeRange is the default time range of a dashboard in grafana's notation, e.g. now-6h.
+	TimeRange struct {
+		From string `mapstructure:"from" json:"from"`
+		To   string `mapstructure:"to" json:"to"`
+	}
+
+	// Templating holds dashboard variables.
+	Templating struct {
+		List []*TemplateVar `mapstructure:"list" json:"list,omitempty"`
+	}
+	TemplateVar struct {
+		Name    string `mapstructure:"name" json:"name"`
+		Type    string `mapstructure:"type" json:"type"`
+		Query   any    `mapstructure:"query" json:"query,omitempty"`
+		Current struct {
+			Value any `mapstructure:"value" json:"value,omitempty"`
+		} `mapstructure:"current" json:"current"`
+	}
 )
 
+// AllPanels returns the panels of the dashboard along with the ones nested in collapsed rows.
+func (b *Board) AllPanels() []*Panel {
+	res := make([]*Panel, 0, len(b.Panels))
+	for _, p := range b.Panels {
+		res = append(res, p)
+		res = append(res, p.Panels...)
+	}
+	return res
+}
+
 type colBoard uint8
 
 const (
 	colBoardUID colBoard = iota
 	colBoardTitle
 	colBoardPanels
+	colBoardTemplating
+	colBoardRefresh
+	colBoardTime
+	colBoardFolder
+	colBoardFolderUID
+	colBoardSchemaVersion
+	colBoardUpdated
+	colBoardVersion
+	colBoardViews
 	colBoardNum
 )
 
+var boardHeaders = [colBoardNum]string{
+	"uid", "title", "panels", "templating", "refresh", "time",
+	"folder", "folderUid", "schemaVersion", "updated", "version", "views",
+}
+
 func writeAllBoardsCSV(ctx context.Context, file string, boards []*Board) error {
 	f, err := os.Create(file)
 	if err != nil {
@@ -57,7 +129,7 @@ func writeAllBoardsCSV(ctx context.Context, file string, boards []*Board) error
 		w:    csv.NewWriter(f),
 	}
 	err = wr.Write(ctx, func(buf []string) {
-		buf[colBoardUID], buf[colBoardTitle], buf[colBoardPanels] = "uid", "title", "panels"
+		copy(buf, boardHeaders[:])
 	})
 	if err != nil {
 		return fmt.Errorf("write headers: %w", err)
@@ -67,14 +139,40 @@ func writeAllBoardsCSV(ctx context.Context, file string, boards []*Board) error
 		if err != nil {
 			return fmt.Errorf("marshal panels: %w", err)
 		}
+		tmpl, err := json.Marshal(board.Templating)
+		if err != nil {
+			return fmt.Errorf("marshal templating: %w", err)
+		}
+		tr, err := json.Marshal(board.Time)
+		if err != nil {
+			return fmt.Errorf("marshal time: %w", err)
+		}
 		err = wr.Write(ctx, func(buf []string) {
 			buf[colBoardUID] = board.UID
 			buf[colBoardTitle] = board.Title
 			buf[colBoardPanels] = string(jsn)
+			buf[colBoardTemplating] = string(tmpl)
+			buf[colBoardRefresh] = board.Refresh
+			buf[colBoardTime] = string(tr)
+			buf[colBoardFolder] = board.Folder
+			buf[colBoardFolderUID] = board.FolderUID
+			buf[colBoardSchemaVersion] = strconv.FormatUint(uint64(board.SchemaVersion), 10)
+			buf[colBoardUpdated] = ""
+			if !board.Updated.IsZero() {
+				buf[colBoardUpdated] = board.Updated.Format(time.RFC3339)
+			}
+			buf[colBoardVersion] = strconv.FormatInt(board.Version, 10)
+			buf[colBoardViews] = ""
+			if board.Views != nil {
+				buf[colBoardViews] = strconv.FormatInt(*board.Views, 10)
+			}
 		})
+		if err != nil {
+			return fmt.Errorf("write board: %w", err)
+		}
 	}
 	wr.Flush()
-	return nil
+	return wr.w.Error()
 }
 
 func readAllBoardsCSV(ctx context.Context, file string) ([]*Board, []error, error) {
@@ -87,8 +185,9 @@ func readAllBoardsCSV(ctx context.Context, file string) ([]*Board, []error, erro
 	}()
 
 	r := csv.NewReader(f)
-	if _, err = r.Read(); err != nil { // reading header
-		return nil, nil, fmt.Errorf("read header: %w", err)
+	h, err := readCSVHeader(r)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var (
@@ -110,15 +209,54 @@ OUT:
 				continue
 			}
 
-			var panels []*Panel
-			if err := json.Unmarshal([]byte(rec[2]), &panels); err != nil {
-				return nil, nil, fmt.Errorf("marshal panels: %w", err)
+			board := Board{
+				UID:       h.get(rec, boardHeaders[colBoardUID]),
+				Title:     h.get(rec, boardHeaders[colBoardTitle]),
+				Refresh:   h.get(rec, boardHeaders[colBoardRefresh]),
+				Folder:    h.get(rec, boardHeaders[colBoardFolder]),
+				FolderUID: h.get(rec, boardHeaders[colBoardFolderUID]),
+			}
+			if err := json.Unmarshal([]byte(h.get(rec, boardHeaders[colBoardPanels])), &board.Panels); err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("unmarshal panels of %q: %w", board.UID, err))
+				continue
+			}
+			// templating is missing in the exports of older versions
+			if s := h.get(rec, boardHeaders[colBoardTemplating]); s != "" {
+				if err := json.Unmarshal([]byte(s), &board.Templating); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("unmarshal templating: %w", err))
+				}
+			}
+			if s := h.get(rec, boardHeaders[colBoardSchemaVersion]); s != "" {
+				v, err := strconv.ParseUint(s, 10, 32)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse schema version of %q: %w", board.UID, err))
+				}
+				board.SchemaVersion = uint(v)
+			}
+			if s := h.get(rec, boardHeaders[colBoardUpdated]); s != "" {
+				if board.Updated, err = time.Parse(time.RFC3339, s); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse updated of %q: %w", board.UID, err))
+				}
+			}
+			if s := h.get(rec, boardHeaders[colBoardVersion]); s != "" {
+				if board.Version, err = strconv.ParseInt(s, 10, 64); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse version of %q: %w", board.UID, err))
+				}
+			}
+			if s := h.get(rec, boardHeaders[colBoardViews]); s != "" {
+				views, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse views of %q: %w", board.UID, err))
+				} else {
+					board.Views = &views
+				}
+			}
+			if s := h.get(rec, boardHeaders[colBoardTime]); s != "" {
+				if err := json.Unmarshal([]byte(s), &board.Time); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("unmarshal time of %q: %w", board.UID, err))
+				}
 			}
-			boards = append(boards, &Board{
-				UID:    rec[colBoardUID],
-				Title:  rec[colBoardTitle],
-				Panels: panels,
-			})
+			boards = append(boards, &board)
 		}
 	}
 	return boards, silentErrs, nil
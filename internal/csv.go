@@ -37,3 +37,28 @@ func (wr *csvBatchWriter) Write(ctx context.Context, fn func(buf []string)) erro
 func (wr *csvBatchWriter) Flush() {
 	wr.w.Flush()
 }
+
+// csvHeader maps column names to their positions,
+// so that files exported by older versions with fewer columns can still be read.
+type csvHeader map[string]int
+
+func readCSVHeader(r *csv.Reader) (csvHeader, error) {
+	rec, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	h := make(csvHeader, len(rec))
+	for i, col := range rec {
+		h[col] = i
+	}
+	return h, nil
+}
+
+// get returns the value of the column in the record, empty if the column is missing.
+func (h csvHeader) get(rec []string, col string) string {
+	i, ok := h[col]
+	if !ok || i >= len(rec) {
+		return ""
+	}
+	return rec[i]
+}
@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type DiffConfig struct {
+	Old, New *Source
+	VarsFile string
+	// SeriesThreshold is the number of series from which on a metric is considered high cardinality.
+	SeriesThreshold uint64
+	// EvalRegression is the growth ratio of a rule's evaluation time that is reported as a regression.
+	EvalRegression float64
+}
+
+// NewSourceFromDir returns the source of an export directory,
+// which is either a snapshot or a directory holding the csv files of an older export.
+func NewSourceFromDir(dir string) *Source {
+	if _, err := os.Stat(filepath.Join(dir, manifestFile)); err == nil {
+		return &Source{Snapshot: dir}
+	}
+	return &Source{
+		RulesFile:      filepath.Join(dir, "rules.csv"),
+		MetricsFile:    filepath.Join(dir, "metrics.csv"),
+		DashboardsFile: filepath.Join(dir, "dashboards.csv"),
+	}
+}
+
+type (
+	DiffResult struct {
+		AddedMetrics, RemovedMetrics []MetricName
+		// HighCardinalityMetrics are the metrics that newly reached the series threshold.
+		HighCardinalityMetrics   []*Metric
+		AddedRules, RemovedRules []Rule
+		ChangedRules             []RuleChange
+		AddedBoards              []Board
+		RemovedBoards            []Board
+		ChangedBoards            []BoardChange
+		// BrokenBoards are the dashboards that miss metrics in the new snapshot but didn't in the old one.
+		BrokenBoards []IdleDashboard
+		ParseErrs    []error
+	}
+	RuleChange struct {
+		Old, New Rule
+		Changes  []string
+	}
+	BoardChange struct {
+		Board   Board
+		Changes []string
+	}
+)
+
+// dataset is the full content of a source.
+type dataset struct {
+	metrics Metrics
+	rules   []Rule
+	boards  []*Board
+}
+
+func (s *Source) readAll(ctx context.Context) (*dataset, []error, error) {
+	var (
+		ds        dataset
+		errs      [3][]error
+		eg, egctx = errgroup.WithContext(ctx)
+	)
+	eg.Go(func() error {
+		res, se, err := s.readMetrics(egctx)
+		ds.metrics, errs[0] = res, se
+		return err
+	})
+	eg.Go(func() error {
+		res, se, err := s.readRules(egctx)
+		ds.rules, errs[1] = res, se
+		return err
+	})
+	eg.Go(func() error {
+		res, se, err := s.readBoards(egctx)
+		ds.boards, errs[2] = res, se
+		return err
+	})
+	if err := eg.Wait(); err != nil {
+		return nil, nil, fmt.Errorf("wait eg: %w", err)
+	}
+	return &ds, slices.Concat(errs[:]...), nil
+}
+
+type Differ struct {
+	cfg *DiffConfig
+}
+
+func NewDiffer(cfg *DiffConfig) *Differ {
+	return &Differ{cfg: cfg}
+}
+
+func (d *Differ) Diff(ctx context.Context) (*DiffResult, error) {
+	old, silentErrs, err := d.cfg.Old.readAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read old: %w", err)
+	}
+	cur, se, err := d.cfg.New.readAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("read new: %w", err)
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(d.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var res DiffResult
+	d.diffMetrics(&res, old.metrics, cur.metrics)
+	d.diffRules(&res, old.rules, cur.rules)
+	d.diffBoards(&res, old.boards, cur.boards)
+	res.BrokenBoards, se = d.newlyBroken(old, cur, vars)
+	res.ParseErrs = append(silentErrs, se...)
+	return &res, nil
+}
+
+func (d *Differ) diffMetrics(res *DiffResult, old, cur Metrics) {
+	for name, m := range cur {
+		prev, ok := old[name]
+		if !ok {
+			res.AddedMetrics = append(res.AddedMetrics, name)
+		}
+		if d.cfg.SeriesThreshold == 0 || m.Series < d.cfg.SeriesThreshold {
+			continue
+		}
+		if !ok || prev.Series < d.cfg.SeriesThreshold {
+			res.HighCardinalityMetrics = append(res.HighCardinalityMetrics, m)
+		}
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			res.RemovedMetrics = append(res.RemovedMetrics, name)
+		}
+	}
+	slices.Sort(res.AddedMetrics)
+	slices.Sort(res.RemovedMetrics)
+	sort.Slice(res.HighCardinalityMetrics, func(i, j int) bool {
+		return res.HighCardinalityMetrics[i].Series > res.HighCardinalityMetrics[j].Series
+	})
+}
+
+func (d *Differ) diffRules(res *DiffResult, old, cur []Rule) {
+	oldByKey, curByKey := rulesByKey(old), rulesByKey(cur)
+	keys := make(map[string]struct{}, len(curByKey))
+	for key := range oldByKey {
+		keys[key] = struct{}{}
+	}
+	for key := range curByKey {
+		keys[key] = struct{}{}
+	}
+	for _, key := range sortedKeys(keys) {
+		pairs, added, removed := matchRules(oldByKey[key], curByKey[key])
+		res.AddedRules = append(res.AddedRules, added...)
+		res.RemovedRules = append(res.RemovedRules, removed...)
+		for _, p := range pairs {
+			prev, r := p.Old, p.New
+			var changes []string
+			if prev.Query != r.Query {
+				changes = append(changes, "query")
+			}
+			if !prev.Labels.Equal(r.Labels) {
+				changes = append(changes, "labels")
+			}
+			if prev.EvalDuration > 0 && r.EvalDuration > prev.EvalDuration*d.cfg.EvalRegression {
+				changes = append(changes, fmt.Sprintf("eval-time %gs -> %gs", prev.EvalDuration, r.EvalDuration))
+			}
+			if len(changes) > 0 {
+				res.ChangedRules = append(res.ChangedRules, RuleChange{Old: prev, New: r, Changes: changes})
+			}
+		}
+	}
+}
+
+// rulesByKey groups rules by their group, type & name, grafana managed ones by their folder & ref id as well.
+// Rules sharing those, e.g. alerts of different severities, are kept in their order.
+func rulesByKey(rules []Rule) map[string][]Rule {
+	res := make(map[string][]Rule, len(rules))
+	for _, r := range rules {
+		key := strings.Join([]string{r.Group, r.Type, r.Name}, "/")
+		if r.IsGrafana() {
+			key = strings.Join([]string{r.Source, r.Folder, key, r.RefID}, "/")
+		}
+		res[key] = append(res[key], r)
+	}
+	return res
+}
+
+// matchRules pairs the old & new rules sharing a key by their content: the ones of the same query & labels first,
+// then the ones of the same labels, the rest by their order. Inserting, removing or reordering one of them
+// thus leaves the others matched. Rules left unmatched are returned as added & removed.
+func matchRules(old, cur []Rule) (pairs []RuleChange, added, removed []Rule) {
+	oldTaken, curTaken := make([]bool, len(old)), make([]bool, len(cur))
+	matched := make([]int, len(cur))
+	for _, same := range []func(o, c Rule) bool{
+		func(o, c Rule) bool { return o.Query == c.Query && o.Labels.Equal(c.Labels) },
+		func(o, c Rule) bool { return o.Labels.Equal(c.Labels) },
+		func(Rule, Rule) bool { return true },
+	} {
+		for i, c := range cur {
+			if curTaken[i] {
+				continue
+			}
+			for j, o := range old {
+				if !oldTaken[j] && same(o, c) {
+					curTaken[i], oldTaken[j], matched[i] = true, true, j
+					break
+				}
+			}
+		}
+	}
+	for i, c := range cur {
+		if curTaken[i] {
+			pairs = append(pairs, RuleChange{Old: old[matched[i]], New: c})
+		} else {
+			added = append(added, c)
+		}
+	}
+	for j, o := range old {
+		if !oldTaken[j] {
+			removed = append(removed, o)
+		}
+	}
+	return pairs, added, removed
+}
+
+func (d *Differ) diffBoards(res *DiffResult, old, cur []*Board) {
+	oldByUID, curByUID := boardsByUID(old), boardsByUID(cur)
+	for _, uid := range sortedKeys(curByUID) {
+		b := curByUID[uid]
+		prev, ok := oldByUID[uid]
+		if !ok {
+			res.AddedBoards = append(res.AddedBoards, Board{UID: b.UID, Title: b.Title})
+			continue
+		}
+		if changes := boardChanges(prev, b); len(changes) > 0 {
+			res.ChangedBoards = append(res.ChangedBoards, BoardChange{
+				Board:   Board{UID: b.UID, Title: b.Title},
+				Changes: changes,
+			})
+		}
+	}
+	for _, uid := range sortedKeys(oldByUID) {
+		if _, ok := curByUID[uid]; !ok {
+			b := oldByUID[uid]
+			res.RemovedBoards = append(res.RemovedBoards, Board{UID: b.UID, Title: b.Title})
+		}
+	}
+}
+
+func boardChanges(old, cur *Board) []string {
+	var changes []string
+	if old.Title != cur.Title {
+		changes = append(changes, "title")
+	}
+	oldPanels, curPanels := panelsByID(old), panelsByID(cur)
+	var added, removed, changed []uint
+	for id, p := range curPanels {
+		prev, ok := oldPanels[id]
+		if !ok {
+			added = append(added, id)
+			continue
+		}
+		if !slices.Equal(panelExprs(prev), panelExprs(p)) {
+			changed = append(changed, id)
+		}
+	}
+	for id := range oldPanels {
+		if _, ok := curPanels[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	for _, c := range []struct {
+		name string
+		ids  []uint
+	}{{"panels added", added}, {"panels removed", removed}, {"queries changed", changed}} {
+		if len(c.ids) > 0 {
+			slices.Sort(c.ids)
+			changes = append(changes, fmt.Sprintf("%s: %v", c.name, c.ids))
+		}
+	}
+	return changes
+}
+
+// newlyBroken returns the boards of the new dataset that miss metrics they didn't miss in the old one,
+// along with those newly missing metrics.
+func (d *Differ) newlyBroken(old, cur *dataset, vars Variables) ([]IdleDashboard, []error) {
+	var (
+		silentErrs []error
+		oldBoards  = boardsByUID(old.boards)
+		oldRules   = distinctRuleNames(old.rules)
+		curRules   = distinctRuleNames(cur.rules)
+		res        []IdleDashboard
+	)
+	for _, b := range cur.boards {
+		missings, se := boardMissingMetrics(b, mergeVariables(b.Variables(), vars), curRules, cur.metrics)
+		silentErrs = append(silentErrs, se...)
+		if prev, ok := oldBoards[b.UID]; ok && len(missings) > 0 {
+			// parse errors were already reported for the new board
+			prevMissings, _ := boardMissingMetrics(prev, mergeVariables(prev.Variables(), vars), oldRules, old.metrics)
+			for m := range prevMissings {
+				delete(missings, m)
+			}
+		}
+		if len(missings) == 0 {
+			continue
+		}
+		res = append(res, IdleDashboard{
+			Board:    Board{UID: b.UID, Title: b.Title},
+			Missings: missings,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Board.UID < res[j].Board.UID
+	})
+	return res, silentErrs
+}
+
+func boardsByUID(boards []*Board) map[string]*Board {
+	res := make(map[string]*Board, len(boards))
+	for _, b := range boards {
+		res[b.UID] = b
+	}
+	return res
+}
+
+func panelsByID(b *Board) map[uint]*Panel {
+	res := make(map[uint]*Panel, len(b.Panels))
+	for _, p := range b.AllPanels() {
+		res[p.ID] = p
+	}
+	return res
+}
+
+func panelExprs(p *Panel) []string {
+	res := make([]string, 0, len(p.Targets))
+	for _, t := range p.Targets {
+		res = append(res, t.Expr)
+	}
+	return res
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+type DuplicatesConfig struct {
+	*Source
+	Limit uint64
+}
+
+type (
+	DuplicatesResult struct {
+		Duplicates []DuplicateRules
+		Aliases    []RuleAlias
+		ParseErrs  []error
+	}
+	// DuplicateRules are rules whose queries are the same once normalized.
+	DuplicateRules struct {
+		// Query is the normalized query.
+		Query string
+		// Identical is set when the queries are the same as written.
+		Identical bool
+		// Recording is the name of a recording rule of the group, the others could select its output instead.
+		Recording string
+		Rules     []Rule
+	}
+	// RuleAlias is a recording rule that merely selects the output of another recording rule.
+	RuleAlias struct {
+		Rule Rule
+		Of   string
+	}
+)
+
+type PromRulesDuplicates struct {
+	cfg *DuplicatesConfig
+}
+
+func NewPromRulesDuplicates(cfg *DuplicatesConfig) *PromRulesDuplicates {
+	return &PromRulesDuplicates{cfg: cfg}
+}
+
+// List groups the rules by their normalized queries, largest groups first,
+// & finds recording rules aliasing other recording rules.
+func (prd *PromRulesDuplicates) List(ctx context.Context) (*DuplicatesResult, error) {
+	rules, silentErrs, err := prd.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		byQuery  = make(map[string][]Rule)
+		recorded = make(map[string]struct{})
+		aliases  []RuleAlias
+	)
+	for _, rule := range rules {
+		if rule.Type == "record" {
+			recorded[rule.Name] = struct{}{}
+		}
+	}
+	for _, rule := range rules {
+		expr, err := parseExpr(rule.Query, nil)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse prom expr: %w", err))
+			continue
+		}
+		if vs, ok := unwrapParens(expr).(*parser.VectorSelector); ok && rule.Type == "record" {
+			if name := selectorName(vs); name != rule.Name && len(vs.LabelMatchers) == 1 {
+				if _, ok := recorded[name]; ok {
+					aliases = append(aliases, RuleAlias{Rule: rule, Of: name})
+				}
+			}
+		}
+		norm := normalizeExpr(expr)
+		byQuery[norm] = append(byQuery[norm], rule)
+	}
+
+	var res []DuplicateRules
+	for query, rs := range byQuery {
+		if len(rs) < 2 {
+			continue
+		}
+		dup := DuplicateRules{Query: query, Identical: true, Rules: rs}
+		for _, r := range rs {
+			if r.Query != rs[0].Query {
+				dup.Identical = false
+			}
+			if r.Type == "record" && dup.Recording == "" {
+				dup.Recording = r.Name
+			}
+		}
+		res = append(res, dup)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Rules) != len(res[j].Rules) {
+			return len(res[i].Rules) > len(res[j].Rules)
+		}
+		return res[i].Query < res[j].Query
+	})
+	return &DuplicatesResult{
+		Duplicates: res[:min(prd.cfg.Limit, uint64(len(res)))],
+		Aliases:    aliases,
+		ParseErrs:  silentErrs,
+	}, nil
+}
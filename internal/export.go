@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	goapi "github.com/grafana/grafana-openapi-client-go/client"
@@ -14,6 +15,8 @@ import (
 type ExportConfig struct {
 	Addr   string
 	Output string
+	// Snapshot is the directory to export into, csv output is used if it's empty.
+	Snapshot string
 }
 
 type ExportResult struct {
@@ -21,29 +24,81 @@ type ExportResult struct {
 	ParseErrs []error
 }
 
+type RulesExportConfig struct {
+	*ExportConfig
+	// GrafanaAddr enables exporting grafana managed rules along with the prometheus ones, it's disabled when empty.
+	GrafanaAddr string
+	SvcToken    string
+}
+
 type RulesExporter struct {
-	cfg   *ExportConfig
-	v1api promapiv1.API
+	cfg     *RulesExportConfig
+	prom    *promClient
+	grafana *goapi.GrafanaHTTPAPI
 }
 
-func NewRulesExporter(cfg *ExportConfig) (*RulesExporter, error) {
-	return &RulesExporter{
-		cfg:   cfg,
-		v1api: mustNewPromAPIV1(cfg.Addr),
-	}, nil
+func NewRulesExporter(cfg *RulesExportConfig) (*RulesExporter, error) {
+	re := &RulesExporter{
+		cfg:  cfg,
+		prom: mustNewPromClient(cfg.Addr),
+	}
+	if cfg.GrafanaAddr != "" {
+		re.grafana = newGrafanaOAPI(&GrafanaConfig{
+			URL:    cfg.GrafanaAddr,
+			Scheme: "https",
+			APIKey: cfg.SvcToken,
+		})
+	}
+	return re, nil
 }
 
 func (re *RulesExporter) Export(ctx context.Context) error {
-	rules, err := re.v1api.Rules(ctx)
+	groups, err := re.prom.ruleGroups(ctx)
 	if err != nil {
 		return fmt.Errorf("get rules: %w", err)
 	}
+	rules := rulesFrom(groups)
+	if re.grafana != nil {
+		grules, err := re.grafanaRules(ctx)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, grules...)
+	}
+	if re.cfg.Snapshot != "" {
+		return writeSnapshotEntity(ctx, re.cfg.Snapshot, entityRules, re.cfg.Addr, rules)
+	}
 	return writeAllRulesCSV(ctx, re.cfg.Output, rules)
 }
 
+// grafanaRules returns the grafana managed rules of the provisioning api with their folder titles,
+// keeping the queries of prometheus datasources only.
+func (re *RulesExporter) grafanaRules(ctx context.Context) ([]Rule, error) {
+	grules, err := getAlertRules(ctx, re.grafana)
+	if err != nil {
+		return nil, fmt.Errorf("get grafana rules: %w", err)
+	}
+	dsTypes, err := getDatasourceTypes(ctx, re.grafana)
+	if err != nil {
+		return nil, fmt.Errorf("get datasource types: %w", err)
+	}
+	folders, err := getFolderTitles(ctx, re.grafana)
+	if err != nil {
+		return nil, fmt.Errorf("get folder titles: %w", err)
+	}
+	rules := grafanaRulesFrom(grules, dsTypes, folders)
+	slog.InfoContext(ctx, "Fetched grafana rules",
+		slog.Int("total", len(grules)),
+		slog.Int("queries", len(rules)),
+	)
+	return rules, nil
+}
+
 type MetricsExporterConfig struct {
 	*ExportConfig
 	Since string
+	// CardinalityLimit is the number of metrics with the most series whose series counts are exported.
+	CardinalityLimit uint64
 }
 
 type MetricsExporter struct {
@@ -65,13 +120,67 @@ func (mex *MetricsExporter) Export(ctx context.Context) error {
 	}
 
 	start, end := time.Now().Add(-1*since), time.Now()
-	metrics, _, err := mex.v1api.LabelValues(ctx, labels.MetricName, nil, start, end)
+	names, _, err := mex.v1api.LabelValues(ctx, labels.MetricName, nil, start, end)
 	if err != nil {
 		return fmt.Errorf("get metrics: %w", err)
 	}
+	// backends like thanos lack the tsdb stats, their metrics are exported without series counts
+	series, err := mex.seriesCounts(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Series counts are skipped", slog.Any("err", err))
+	}
+	meta, err := mex.v1api.Metadata(ctx, "", "")
+	if err != nil {
+		return fmt.Errorf("get metadata: %w", err)
+	}
+	metrics := make([]*Metric, 0, len(names))
+	for _, name := range names {
+		m := &Metric{
+			Name:   MetricName(name),
+			Series: series[MetricName(name)],
+		}
+		if md, ok := metadataOf(meta, string(name)); ok {
+			m.Type, m.Help, m.Unit = string(md.Type), md.Help, md.Unit
+		}
+		metrics = append(metrics, m)
+	}
+	if mex.cfg.Snapshot != "" {
+		return writeSnapshotEntity(ctx, mex.cfg.Snapshot, entityMetrics, mex.cfg.Addr, metrics)
+	}
 	return writeAllMetricsCSV(ctx, mex.cfg.Output, metrics)
 }
 
+// metadataOf returns the metadata of a series name, falling back to the metric family
+// of the suffixed series of counters, histograms & summaries.
+func metadataOf(meta map[string][]promapiv1.Metadata, name string) (promapiv1.Metadata, bool) {
+	if md := meta[name]; len(md) > 0 {
+		return md[0], true
+	}
+	for _, suffix := range []string{"_total", "_bucket", "_count", "_sum", "_created"} {
+		if family, ok := strings.CutSuffix(name, suffix); ok {
+			if md := meta[family]; len(md) > 0 {
+				return md[0], true
+			}
+		}
+	}
+	return promapiv1.Metadata{}, false
+}
+
+func (mex *MetricsExporter) seriesCounts(ctx context.Context) (map[MetricName]uint64, error) {
+	if mex.cfg.CardinalityLimit == 0 {
+		return nil, nil
+	}
+	res, err := mex.v1api.TSDB(ctx, promapiv1.WithLimit(mex.cfg.CardinalityLimit))
+	if err != nil {
+		return nil, fmt.Errorf("get tsdb stats: %w", err)
+	}
+	counts := make(map[MetricName]uint64, len(res.SeriesCountByMetricName))
+	for _, st := range res.SeriesCountByMetricName {
+		counts[MetricName(st.Name)] = st.Value
+	}
+	return counts, nil
+}
+
 type DashboardsExportConfig struct {
 	*ExportConfig
 	SvcToken string
@@ -105,6 +214,12 @@ func (dex *DashboardsExporter) Export(ctx context.Context) (*ExportResult, error
 
 	boards := make([]*Board, 0, c)
 	var silentErrs []error
+	// views are only known with usage insights, they're left unknown otherwise
+	views, err := getDashboardViews(ctx, dex.grafana)
+	if err != nil {
+		silentErrs = append(silentErrs, fmt.Errorf("get views: %w", err))
+	}
+	libraryPanels := make(map[string]*Panel)
 	for _, uid := range boardIDs {
 		slog.Debug("Fetching board", slog.String("uid", uid))
 		db, err := getDashboardByUID(ctx, dex.grafana, uid)
@@ -112,10 +227,54 @@ func (dex *DashboardsExporter) Export(ctx context.Context) (*ExportResult, error
 			silentErrs = append(silentErrs, fmt.Errorf("get board: %w", err))
 			continue
 		}
+		if v, ok := views[uid]; ok {
+			db.Views = &v
+		}
+		silentErrs = append(silentErrs, dex.resolveLibraryPanels(ctx, db, libraryPanels)...)
 		boards = append(boards, db)
 	}
-	return &ExportResult{
+	res := &ExportResult{
 		Total:     len(boardIDs),
 		ParseErrs: silentErrs,
-	}, writeAllBoardsCSV(ctx, dex.cfg.Output, boards)
+	}
+	if dex.cfg.Snapshot != "" {
+		return res, writeSnapshotEntity(ctx, dex.cfg.Snapshot, entityDashboards, dex.cfg.Addr, boards)
+	}
+	return res, writeAllBoardsCSV(ctx, dex.cfg.Output, boards)
+}
+
+// resolveLibraryPanels inlines the models of the library panels used in the board,
+// which dashboards only reference by uid. Library panels are fetched once & kept in cache, failed ones as nil.
+func (dex *DashboardsExporter) resolveLibraryPanels(ctx context.Context, board *Board, cache map[string]*Panel) []error {
+	var silentErrs []error
+	for _, panel := range board.AllPanels() {
+		if panel.LibraryPanel == nil || panel.LibraryPanel.UID == "" {
+			continue
+		}
+		uid := panel.LibraryPanel.UID
+		lib, ok := cache[uid]
+		if !ok {
+			model, name, err := getLibraryPanel(ctx, dex.grafana, uid)
+			if err != nil {
+				// failures are cached as nil, they're reported once for the first board
+				cache[uid] = nil
+				silentErrs = append(silentErrs, fmt.Errorf("get library panel %s of %q: %w", uid, board.UID, err))
+				continue
+			}
+			model.LibraryPanel = &LibraryPanelRef{UID: uid, Name: name}
+			lib, cache[uid] = model, model
+		}
+		if lib == nil {
+			continue
+		}
+		if panel.Title == "" {
+			panel.Title = lib.Title
+		}
+		panel.Type, panel.Targets = lib.Type, lib.Targets
+		panel.Interval, panel.MaxDataPoints = lib.Interval, lib.MaxDataPoints
+		if panel.LibraryPanel.Name == "" {
+			panel.LibraryPanel.Name = lib.LibraryPanel.Name
+		}
+	}
+	return silentErrs
 }
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMetricsExporterWithoutTSDBStats(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/label/__name__/values", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":["up","http_requests_total"]}`))
+	})
+	mux.HandleFunc("/api/v1/metadata", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"up":[{"type":"gauge","help":"","unit":""}]}}`))
+	})
+	// like thanos, the tsdb stats are unknown
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "metrics.csv")
+	exp, err := NewMetricsExporter(&MetricsExporterConfig{
+		ExportConfig:     &ExportConfig{Addr: srv.URL, Output: out},
+		Since:            "1h",
+		CardinalityLimit: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = exp.Export(context.Background()); err != nil {
+		t.Fatalf("export failed without tsdb stats: %v", err)
+	}
+
+	metrics, err := readAllMetricsCSV(context.Background(), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metrics) != 2 {
+		t.Fatalf("got %d metrics, want 2", len(metrics))
+	}
+	if m := metrics["up"]; m == nil || m.Type != "gauge" || m.Series != 0 {
+		t.Errorf("got up %+v, want a gauge without series count", m)
+	}
+}
+
+func TestDashboardsExporterCachesFailedLibraryPanels(t *testing.T) {
+	fg := newFakeGrafana()
+	for _, uid := range []string{"a", "b"} {
+		fg.addDashboard("", map[string]any{
+			"uid":   uid,
+			"title": "board " + uid,
+			"panels": []any{
+				map[string]any{"id": 1, "libraryPanel": map[string]any{"uid": "missing", "name": "gone"}},
+			},
+		})
+	}
+	addr := fg.start(t)
+
+	exp, err := NewDashboardsExporter(&DashboardsExportConfig{
+		ExportConfig: &ExportConfig{Addr: addr, Output: filepath.Join(t.TempDir(), "dashboards.csv")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := exp.Export(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := fg.count(http.MethodGet, "/api/library-elements/missing"); c != 1 {
+		t.Errorf("got %d library panel requests, want 1", c)
+	}
+	var libErrs int
+	for _, err := range res.ParseErrs {
+		if strings.Contains(err.Error(), "get library panel missing") {
+			libErrs++
+		}
+	}
+	if libErrs != 1 {
+		t.Errorf("got %d library panel errors, want 1: %v", libErrs, res.ParseErrs)
+	}
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"context"
+	"sort"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+type FailingConfig struct {
+	*Source
+	Limit uint64
+}
+
+type (
+	FailingRulesResult struct {
+		Errors    []RulesByError
+		ParseErrs []error
+	}
+	// RulesByError groups the rules failing with the same error.
+	RulesByError struct {
+		Error string
+		Rules []Rule
+	}
+)
+
+type PromRulesFailing struct {
+	cfg *FailingConfig
+}
+
+func NewPromRulesFailing(cfg *FailingConfig) *PromRulesFailing {
+	return &PromRulesFailing{cfg: cfg}
+}
+
+// List returns the rules in error state grouped by their last error, most common errors first.
+func (prf *PromRulesFailing) List(ctx context.Context) (*FailingRulesResult, error) {
+	rules, silentErrs, err := prf.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byErr := make(map[string][]Rule)
+	for _, rule := range rules {
+		if rule.Health != string(promapiv1.RuleHealthBad) {
+			continue
+		}
+		byErr[rule.LastError] = append(byErr[rule.LastError], rule)
+	}
+	res := make([]RulesByError, 0, len(byErr))
+	for msg, rs := range byErr {
+		res = append(res, RulesByError{Error: msg, Rules: rs})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Rules) != len(res[j].Rules) {
+			return len(res[i].Rules) > len(res[j].Rules)
+		}
+		return res[i].Error < res[j].Error
+	})
+	return &FailingRulesResult{
+		Errors:    res[:min(prf.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/go-openapi/runtime"
 	rtclient "github.com/go-openapi/runtime/client"
@@ -14,6 +16,7 @@ import (
 	"github.com/go-viper/mapstructure/v2"
 	goapi "github.com/grafana/grafana-openapi-client-go/client"
 	"github.com/grafana/grafana-openapi-client-go/client/search"
+	"github.com/grafana/grafana-openapi-client-go/models"
 	"github.com/hashicorp/go-cleanhttp"
 )
 
@@ -21,24 +24,36 @@ type GrafanaConfig struct {
 	URL    string
 	Scheme string
 	APIKey string
+	// OrgID is the organization the requests apply to, the one of the token when zero.
+	OrgID int64
 }
 
 func newGrafanaOAPI(cfg *GrafanaConfig) *goapi.GrafanaHTTPAPI {
+	scheme, host := grafanaSchemeHost(cfg.URL, cfg.Scheme)
 	tc := &goapi.TransportConfig{
 		Client: cleanhttp.DefaultPooledClient(),
 		// Host is the domain name or IP address of the host that serves the API.
-		Host: cfg.URL,
+		Host: host,
 		// BasePath is the URL prefix for all API paths, relative to the host root.
 		BasePath: "/api",
 		// Schemes are the transfer protocols used by the API (http or https).
-		Schemes: []string{cfg.Scheme},
+		Schemes: []string{scheme},
 		// TLSConfig provides an optional configuration for a TLS client
 		TLSConfig: &tls.Config{},
 		APIKey:    cfg.APIKey,
+		OrgID:     cfg.OrgID,
 	}
 	return goapi.New(newOAPITransportWithConfig(tc), tc, strfmt.Default)
 }
 
+// grafanaSchemeHost splits a grafana address into its scheme & host, addresses without scheme get the default one.
+func grafanaSchemeHost(addr, defaultScheme string) (string, string) {
+	if u, err := url.Parse(addr); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme, u.Host
+	}
+	return defaultScheme, addr
+}
+
 // newOAPITransportWithConfig is inline from https://github.com/grafana/grafana-openapi-client-go/blob/main/client/grafana_http_api_client.go#L420-L462.
 // As it is not allowed to configure through interface.
 func newOAPITransportWithConfig(cfg *goapi.TransportConfig) *rtclient.Runtime {
@@ -101,20 +116,147 @@ func getAllDashboards(ctx context.Context, graf *goapi.GrafanaHTTPAPI) ([]string
 	return results, nil
 }
 
-func getDashboardByUID(ctx context.Context, graf *goapi.GrafanaHTTPAPI, uid string) (*Board, error) {
+// viewsSort is the search sort of grafana's usage insights, the views of the last 30 days are returned as sort meta.
+const viewsSort = "views-recent-desc"
+
+// recentViewsWindow is the period the views of viewsSort cover.
+const recentViewsWindow = 30 * 24 * time.Hour
+
+// getDashboardViews returns the recent views of dashboards, it fails on grafana instances without usage insights.
+func getDashboardViews(ctx context.Context, graf *goapi.GrafanaHTTPAPI) (map[string]int64, error) {
+	var (
+		typ, sort         = "dash-db", viewsSort
+		page, limit int64 = 1, 100
+		results           = make(map[string]int64)
+	)
+	for {
+		resp, err := graf.Search.Search(&search.SearchParams{
+			Limit:   &limit,
+			Page:    &page,
+			Type:    &typ,
+			Sort:    &sort,
+			Context: ctx,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("dashboard search by views: %w", err)
+		}
+		if len(resp.Payload) == 0 {
+			break
+		}
+		for _, db := range resp.Payload {
+			// unknown sorts are ignored by some versions rather than refused
+			if db.SortMetaName == "" {
+				return nil, fmt.Errorf("search sort %s isn't supported", viewsSort)
+			}
+			results[db.UID] = db.SortMeta
+		}
+		page++
+	}
+	return results, nil
+}
+
+// getDashboardModelByUID returns the full json model of the dashboard along with its meta.
+func getDashboardModelByUID(ctx context.Context, graf *goapi.GrafanaHTTPAPI, uid string) (*models.DashboardFullWithMeta, error) {
 	resp, err := graf.Dashboards.GetDashboardByUID(uid, func(op *runtime.ClientOperation) {
 		op.Context = ctx
 	})
 	if err != nil {
 		return nil, fmt.Errorf("get dashboard by uid: %w", err)
 	}
-	raw, ok := resp.Payload.Dashboard.(map[string]any)
+	return resp.Payload, nil
+}
+
+func getDashboardByUID(ctx context.Context, graf *goapi.GrafanaHTTPAPI, uid string) (*Board, error) {
+	payload, err := getDashboardModelByUID(ctx, graf, uid)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := payload.Dashboard.(map[string]any)
 	if !ok {
 		return nil, fmt.Errorf("payload can't be casted, uid: %s", uid)
 	}
+	// refresh is false rather than an interval when disabled
+	if _, ok := raw["refresh"].(string); !ok {
+		delete(raw, "refresh")
+	}
 	var board Board
 	if err = mapstructure.Decode(raw, &board); err != nil {
 		return nil, fmt.Errorf("decode dashboard: %w", err)
 	}
+	if meta := payload.Meta; meta != nil {
+		board.Folder, board.FolderUID = meta.FolderTitle, meta.FolderUID
+		board.Updated, board.Version = time.Time(meta.Updated), meta.Version
+	}
 	return &board, nil
 }
+
+// getFolderTitles returns the titles of all folders by uid.
+func getFolderTitles(ctx context.Context, graf *goapi.GrafanaHTTPAPI) (map[string]string, error) {
+	var (
+		typ               = "dash-folder"
+		page, limit int64 = 1, 100
+		results           = make(map[string]string)
+	)
+	for {
+		resp, err := graf.Search.Search(&search.SearchParams{
+			Limit:   &limit,
+			Page:    &page,
+			Type:    &typ,
+			Context: ctx,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("folder search: %w", err)
+		}
+		if len(resp.Payload) == 0 {
+			break
+		}
+		for _, f := range resp.Payload {
+			results[f.UID] = f.Title
+		}
+		page++
+	}
+	return results, nil
+}
+
+// getDatasourceTypes returns the plugin types of all datasources by uid.
+func getDatasourceTypes(ctx context.Context, graf *goapi.GrafanaHTTPAPI) (map[string]string, error) {
+	resp, err := graf.Datasources.GetDataSources(func(op *runtime.ClientOperation) {
+		op.Context = ctx
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get datasources: %w", err)
+	}
+	results := make(map[string]string, len(resp.Payload))
+	for _, ds := range resp.Payload {
+		results[ds.UID] = ds.Type
+	}
+	return results, nil
+}
+
+func getAlertRules(ctx context.Context, graf *goapi.GrafanaHTTPAPI) (models.ProvisionedAlertRules, error) {
+	resp, err := graf.Provisioning.GetAlertRules(func(op *runtime.ClientOperation) {
+		op.Context = ctx
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get alert rules: %w", err)
+	}
+	return resp.Payload, nil
+}
+
+// getLibraryPanel returns the model of a library panel along with its name.
+func getLibraryPanel(ctx context.Context, graf *goapi.GrafanaHTTPAPI, uid string) (*Panel, string, error) {
+	resp, err := graf.LibraryElements.GetLibraryElementByUID(uid, func(op *runtime.ClientOperation) {
+		op.Context = ctx
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("get library element by uid: %w", err)
+	}
+	if resp.Payload == nil || resp.Payload.Result == nil {
+		return nil, "", fmt.Errorf("library element %s has no result", uid)
+	}
+	var panel Panel
+	if err = mapstructure.Decode(resp.Payload.Result.Model, &panel); err != nil {
+		return nil, "", fmt.Errorf("decode library panel: %w", err)
+	}
+	return &panel, resp.Payload.Result.Name, nil
+}
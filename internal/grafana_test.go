@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// every httptest tls server serves the same certificate, grafana clients trust it through the system pool
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	dir, err := os.MkdirTemp("", "owl")
+	if err != nil {
+		panic(err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	srv.Close()
+	file := filepath.Join(dir, "cert.pem")
+	if err = os.WriteFile(file, cert, 0o600); err != nil {
+		panic(err)
+	}
+	if err = os.Setenv("SSL_CERT_FILE", file); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// fakeGrafana serves the parts of grafana's api owl uses from memory.
+type fakeGrafana struct {
+	mu sync.Mutex
+	// dashboards hold the models of the dashboards by uid, along with their folder uid.
+	dashboards map[string]map[string]any
+	dashFolder map[string]string
+	// folders hold the titles of the folders by uid, parents the uids of their parents.
+	folders   map[string]string
+	parents   map[string]string
+	libraries map[string]map[string]any
+	// requests count the requests by method & path.
+	requests map[string]int
+	orgIDs   []string
+}
+
+func newFakeGrafana() *fakeGrafana {
+	return &fakeGrafana{
+		dashboards: make(map[string]map[string]any),
+		dashFolder: make(map[string]string),
+		folders:    make(map[string]string),
+		parents:    make(map[string]string),
+		libraries:  make(map[string]map[string]any),
+		requests:   make(map[string]int),
+	}
+}
+
+// start serves the fake & returns its address as owl expects it, without scheme.
+func (fg *fakeGrafana) start(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewTLSServer(fg)
+	t.Cleanup(srv.Close)
+	return strings.TrimPrefix(srv.URL, "https://")
+}
+
+func (fg *fakeGrafana) addDashboard(folderUID string, model map[string]any) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	uid := model["uid"].(string)
+	fg.dashboards[uid], fg.dashFolder[uid] = model, folderUID
+}
+
+func (fg *fakeGrafana) addFolder(uid, title, parentUID string) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fg.folders[uid] = title
+	if parentUID != "" {
+		fg.parents[uid] = parentUID
+	}
+}
+
+func (fg *fakeGrafana) count(method, path string) int {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	return fg.requests[method+" "+path]
+}
+
+func (fg *fakeGrafana) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fg.requests[r.Method+" "+r.URL.Path]++
+	if id := r.Header.Get("X-Grafana-Org-Id"); id != "" {
+		fg.orgIDs = append(fg.orgIDs, id)
+	}
+	reply := func(code int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	notFound := map[string]string{"message": "not found"}
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodGet && path == "/api/search":
+		q := r.URL.Query()
+		if q.Get("sort") != "" {
+			reply(http.StatusBadRequest, map[string]string{"message": "unknown sort"})
+			return
+		}
+		hits := []map[string]any{}
+		if q.Get("page") == "1" {
+			if q.Get("type") == "dash-folder" {
+				for uid, title := range fg.folders {
+					hits = append(hits, map[string]any{"uid": uid, "title": title, "type": "dash-folder"})
+				}
+			} else {
+				for uid, model := range fg.dashboards {
+					hits = append(hits, map[string]any{"uid": uid, "title": model["title"], "type": "dash-db"})
+				}
+			}
+		}
+		reply(http.StatusOK, hits)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/dashboards/uid/"):
+		uid := strings.TrimPrefix(path, "/api/dashboards/uid/")
+		model, ok := fg.dashboards[uid]
+		if !ok {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		version, _ := model["version"].(float64)
+		if v, ok := model["version"].(int); ok {
+			version = float64(v)
+		}
+		folder := fg.dashFolder[uid]
+		reply(http.StatusOK, map[string]any{
+			"dashboard": model,
+			"meta":      map[string]any{"folderUid": folder, "folderTitle": fg.folders[folder], "version": version},
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/folders/"):
+		uid := strings.TrimPrefix(path, "/api/folders/")
+		title, ok := fg.folders[uid]
+		if !ok {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		// parents are listed from the top level folder down
+		var parents []any
+		for p := fg.parents[uid]; p != ""; p = fg.parents[p] {
+			parents = append([]any{map[string]any{"uid": p, "title": fg.folders[p], "parentUid": fg.parents[p]}}, parents...)
+		}
+		reply(http.StatusOK, map[string]any{"uid": uid, "title": title, "parentUid": fg.parents[uid], "parents": parents})
+	case r.Method == http.MethodPost && path == "/api/folders":
+		var cmd struct {
+			UID       string `json:"uid"`
+			Title     string `json:"title"`
+			ParentUID string `json:"parentUid"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&cmd)
+		if _, ok := fg.folders[cmd.ParentUID]; cmd.ParentUID != "" && !ok {
+			reply(http.StatusBadRequest, map[string]string{"message": "parent folder not found"})
+			return
+		}
+		fg.folders[cmd.UID] = cmd.Title
+		if cmd.ParentUID != "" {
+			fg.parents[cmd.UID] = cmd.ParentUID
+		}
+		reply(http.StatusOK, map[string]any{"uid": cmd.UID, "title": cmd.Title, "parentUid": cmd.ParentUID})
+	case r.Method == http.MethodPost && path == "/api/dashboards/db":
+		var cmd struct {
+			Dashboard map[string]any `json:"dashboard"`
+			FolderUID string         `json:"folderUid"`
+			Overwrite bool           `json:"overwrite"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&cmd)
+		uid, _ := cmd.Dashboard["uid"].(string)
+		prev, exists := fg.dashboards[uid]
+		if exists && !cmd.Overwrite {
+			reply(http.StatusPreconditionFailed, map[string]string{"message": "version-mismatch", "status": "version-mismatch"})
+			return
+		}
+		var version float64 = 1
+		if exists {
+			v, _ := prev["version"].(float64)
+			version = v + 1
+		}
+		cmd.Dashboard["version"] = version
+		fg.dashboards[uid], fg.dashFolder[uid] = cmd.Dashboard, cmd.FolderUID
+		reply(http.StatusOK, map[string]any{"uid": uid, "status": "success", "version": version})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/api/library-elements/"):
+		uid := strings.TrimPrefix(path, "/api/library-elements/")
+		model, ok := fg.libraries[uid]
+		if !ok {
+			reply(http.StatusNotFound, notFound)
+			return
+		}
+		reply(http.StatusOK, map[string]any{"result": map[string]any{"uid": uid, "name": "lib " + uid, "model": model}})
+	default:
+		reply(http.StatusNotFound, map[string]string{"message": "unknown " + strconv.Quote(path)})
+	}
+}
@@ -2,25 +2,37 @@ package internal
 
 import (
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"io"
-	"os"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
 )
 
 type IdlerConfig struct {
-	RulesFile, MetricsFile, DashboardsFile string
-	Limit                                  uint64
+	*Source
+	VarsFile string
+	// Live enables validating selectors against prometheus, it's disabled when nil.
+	Live  *LiveConfig
+	Limit uint64
 }
 
-type RuleMissingMetrics struct {
-	Rule    Rule
-	Metrics MetricNames
-}
+type (
+	IdleRulesResult struct {
+		Rules []RuleMissingMetrics
+		// EmptySelectors are filled in live mode only.
+		EmptySelectors []RuleEmptySelectors
+		ParseErrs      []error
+	}
+	RuleMissingMetrics struct {
+		Rule    Rule
+		Metrics MetricNames
+	}
+	// RuleEmptySelectors is a rule whose selectors don't match any series although their metrics exist.
+	RuleEmptySelectors struct {
+		Rule      Rule
+		Selectors []string
+	}
+)
 
 type PromRulesIdler struct {
 	cfg *IdlerConfig
@@ -32,62 +44,70 @@ func NewPromRulesIdler(cfg *IdlerConfig) *PromRulesIdler {
 	}
 }
 
-func (pri *PromRulesIdler) List(ctx context.Context) ([]RuleMissingMetrics, error) {
-	metrics, err := readAllMetricsCSV(ctx, pri.cfg.MetricsFile)
+func (pri *PromRulesIdler) List(ctx context.Context) (*IdleRulesResult, error) {
+	metrics, silentErrs, err := pri.cfg.readMetrics(ctx)
 	if err != nil {
 		return nil, err
 	}
-	rf, err := os.Open(pri.cfg.RulesFile)
+	vars, err := readVariablesFile(pri.cfg.VarsFile)
 	if err != nil {
-		return nil, fmt.Errorf("open rules: %w", err)
+		return nil, err
 	}
-	defer func() {
-		_ = rf.Close()
-	}()
-
-	rr := csv.NewReader(rf)
-	if _, err = rr.Read(); err != nil { // reading header
-		return nil, fmt.Errorf("read header: %w", err)
+	rules, se, err := pri.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
 	}
+	silentErrs = append(silentErrs, se...)
 
-	var results []RuleMissingMetrics
-EXIT:
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-			if pri.isOffLimit(len(results)) {
-				break EXIT
-			}
-			rec, err := rr.Read()
-			if err == io.EOF {
-				break EXIT
-			}
-			if err != nil {
-				return nil, fmt.Errorf("read rule: %w", err)
-			}
-			rule := Rule{
-				Group: rec[colRuleGroup],
-				Type:  rec[colRuleType],
-				Name:  rec[colRuleName],
-				Query: rec[colRuleQuery],
-			}
-			ms, err := parsePromQuery(rule.Query)
-			if err != nil {
-				return nil, fmt.Errorf("parse prom expr: %w", err)
+	var (
+		results   []RuleMissingMetrics
+		selectors = make(map[int][]string)
+	)
+	for i, rule := range rules {
+		if pri.cfg.Live == nil && pri.isOffLimit(len(results)) {
+			break
+		}
+		pq, err := parsePromQuery(rule.Query, vars)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse prom expr: %w", err))
+			continue
+		}
+		missing, found := missingValues(metrics, pq.names...)
+		if pri.cfg.Live != nil {
+			selectors[i] = liveSelectors(pq, toSet(missing))
+		}
+		if !found || pri.isOffLimit(len(results)) {
+			continue
+		}
+		results = append(results, RuleMissingMetrics{
+			Rule:    rule,
+			Metrics: missing,
+		})
+	}
+	res := &IdleRulesResult{
+		Rules: results,
+	}
+	if pri.cfg.Live != nil {
+		var all []string
+		for _, sels := range selectors {
+			all = append(all, sels...)
+		}
+		empties, se := newSelectorValidator(pri.cfg.Live).emptySelectors(ctx, all)
+		silentErrs = append(silentErrs, se...)
+		for i, rule := range rules {
+			if pri.isOffLimit(len(res.EmptySelectors)) {
+				break
 			}
-			missing, found := missingValues(metrics, ms...)
-			if !found {
-				continue
+			if sels := distinct(filterIn(selectors[i], empties)); len(sels) > 0 {
+				res.EmptySelectors = append(res.EmptySelectors, RuleEmptySelectors{
+					Rule:      rule,
+					Selectors: sels,
+				})
 			}
-			results = append(results, RuleMissingMetrics{
-				Rule:    rule,
-				Metrics: missing,
-			})
 		}
 	}
-	return results, nil
+	res.ParseErrs = silentErrs
+	return res, nil
 }
 
 func (pri *PromRulesIdler) isOffLimit(n int) bool {
@@ -97,12 +117,20 @@ func (pri *PromRulesIdler) isOffLimit(n int) bool {
 type (
 	IdleDashboardsResult struct {
 		IdleDashboards []IdleDashboard
+		// EmptySelectors are filled in live mode only.
+		EmptySelectors []PanelEmptySelectors
 		ParseErrs      []error
 	}
 	IdleDashboard struct {
 		Board    Board
 		Missings map[MetricName]struct{}
 	}
+	// PanelEmptySelectors is a panel whose selectors don't match any series although their metrics exist.
+	PanelEmptySelectors struct {
+		Board     Board
+		Panel     Panel
+		Selectors []string
+	}
 )
 
 type DashboardsIdler struct {
@@ -117,106 +145,139 @@ func NewDashboardsIdler(cfg *IdlerConfig) *DashboardsIdler {
 
 func (dsi *DashboardsIdler) List(ctx context.Context) (*IdleDashboardsResult, error) {
 	var (
-		metrics    map[MetricName]struct{}
-		rules      map[RuleName]struct{}
+		metrics Metrics
+		rules   map[RuleName]struct{}
+
+		mu         sync.Mutex
 		silentErrs []error
 	)
 	eg, egctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
-		res, err := readAllMetricsCSV(egctx, dsi.cfg.MetricsFile)
+		res, se, err := dsi.cfg.readMetrics(egctx)
 		if err != nil {
 			return err
 		}
 		metrics = res
+		mu.Lock()
+		silentErrs = append(silentErrs, se...)
+		mu.Unlock()
 		return nil
 	})
 	eg.Go(func() error {
-		res, se, err := readAllRulesCSV(egctx, dsi.cfg.RulesFile)
+		res, se, err := dsi.cfg.readRules(egctx)
 		if err != nil {
 			return err
 		}
 		rules = distinctRuleNames(res)
-		silentErrs = se
+		mu.Lock()
+		silentErrs = append(silentErrs, se...)
+		mu.Unlock()
 		return nil
 	})
 	if err := eg.Wait(); err != nil {
 		return nil, fmt.Errorf("wait eg: %w", err)
 	}
-	f, err := os.Open(dsi.cfg.DashboardsFile)
+	vars, err := readVariablesFile(dsi.cfg.VarsFile)
 	if err != nil {
-		return nil, fmt.Errorf("open dashboards: %w", err)
+		return nil, err
 	}
-	defer func() {
-		_ = f.Close()
-	}()
-
-	r := csv.NewReader(f)
-	if _, err = r.Read(); err != nil { // reading header
-		return nil, fmt.Errorf("read header: %w", err)
+	boards, se, err := dsi.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
 	}
+	silentErrs = append(silentErrs, se...)
 
-	var idles []IdleDashboard
-OUT:
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-			if dsi.isOffLimit(len(idles)) {
-				break OUT
-			}
-			board, err := r.Read()
-			if err == io.EOF {
-				break OUT
+	var (
+		idles     []IdleDashboard
+		selectors = make(map[*Panel][]string)
+	)
+	for _, board := range boards {
+		if dsi.cfg.Live == nil && dsi.isOffLimit(len(idles)) {
+			break
+		}
+		bvars := mergeVariables(board.Variables(), vars)
+		missings, se := boardMissingMetrics(board, bvars, rules, metrics)
+		silentErrs = append(silentErrs, se...)
+		if dsi.cfg.Live != nil {
+			panelLiveSelectors(board, bvars, missings, selectors)
+		}
+		if len(missings) > 0 && !dsi.isOffLimit(len(idles)) {
+			idles = append(idles, IdleDashboard{
+				Board: Board{
+					UID:   board.UID,
+					Title: board.Title,
+				},
+				Missings: missings,
+			})
+		}
+	}
+	res := &IdleDashboardsResult{
+		IdleDashboards: idles,
+	}
+	if dsi.cfg.Live != nil {
+		var all []string
+		for _, sels := range selectors {
+			all = append(all, sels...)
+		}
+		empties, se := newSelectorValidator(dsi.cfg.Live).emptySelectors(ctx, all)
+		silentErrs = append(silentErrs, se...)
+	OUT:
+		for _, board := range boards {
+			for _, panel := range board.AllPanels() {
+				if dsi.isOffLimit(len(res.EmptySelectors)) {
+					break OUT
+				}
+				if sels := distinct(filterIn(selectors[panel], empties)); len(sels) > 0 {
+					res.EmptySelectors = append(res.EmptySelectors, PanelEmptySelectors{
+						Board:     Board{UID: board.UID, Title: board.Title},
+						Panel:     Panel{ID: panel.ID, Title: panel.Title, Type: panel.Type},
+						Selectors: sels,
+					})
+				}
 			}
-			if err != nil {
-				return nil, fmt.Errorf("read dashboard: %w", err)
+		}
+	}
+	res.ParseErrs = silentErrs
+	return res, nil
+}
+
+// panelLiveSelectors collects the selectors of each panel worth validating live into res.
+// Parse errors are skipped as boardMissingMetrics already reports them.
+func panelLiveSelectors(board *Board, vars Variables, missings map[MetricName]struct{}, res map[*Panel][]string) {
+	for _, panel := range board.AllPanels() {
+		for _, target := range panel.Targets {
+			if target.Expr == "" {
+				continue
 			}
-			missings, se, err := dsi.scanDashboard(board[colBoardPanels], rules, metrics)
+			pq, err := parsePromQuery(target.Expr, vars)
 			if err != nil {
-				return nil, fmt.Errorf("scan dashboard: %w", err)
-			}
-			silentErrs = append(silentErrs, se...)
-			if len(missings) > 0 {
-				idles = append(idles, IdleDashboard{
-					Board: Board{
-						UID:   board[colBoardUID],
-						Title: board[colBoardTitle],
-					},
-					Missings: missings,
-				})
+				continue
 			}
+			res[panel] = append(res[panel], liveSelectors(pq, missings)...)
 		}
 	}
-	return &IdleDashboardsResult{
-		IdleDashboards: idles,
-		ParseErrs:      silentErrs,
-	}, nil
 }
 
-func (dsi *DashboardsIdler) scanDashboard(
-	jsn string,
+// boardMissingMetrics returns the metrics used in the board that exist neither as a metric nor as a recording rule.
+func boardMissingMetrics(
+	board *Board,
+	vars Variables,
 	rules map[RuleName]struct{},
-	metrics map[MetricName]struct{},
-) (map[MetricName]struct{}, []error, error) {
-	var panels []*Panel
-	if err := json.Unmarshal([]byte(jsn), &panels); err != nil {
-		return nil, nil, fmt.Errorf("unmarshal panel: %w", err)
-	}
-
+	metrics Metrics,
+) (map[MetricName]struct{}, []error) {
 	var silentErrs []error
 	missings := make(map[MetricName]struct{})
-	for _, panel := range panels {
+	for _, panel := range board.AllPanels() {
 		for _, target := range panel.Targets {
 			if target.Expr == "" {
 				continue
 			}
-			ms, err := parsePromQuery(target.Expr)
+			pq, err := parsePromQuery(target.Expr, vars)
 			if err != nil {
 				silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
 				continue
 			}
-			for _, m := range ms {
+			for _, m := range pq.names {
 				if _, ok := rules[RuleName(m)]; ok {
 					continue
 				}
@@ -227,7 +288,7 @@ func (dsi *DashboardsIdler) scanDashboard(
 			}
 		}
 	}
-	return missings, silentErrs, nil
+	return missings, silentErrs
 }
 
 func (dsi *DashboardsIdler) isOffLimit(n int) bool {
@@ -251,55 +312,62 @@ func NewMetricsIdler(cfg *IdlerConfig) *MetricsIdler {
 
 func (mi *MetricsIdler) List(ctx context.Context) (*IdleMetricsResult, error) {
 	var (
-		metrics map[MetricName]struct{}
+		metrics Metrics
 		rules   []Rule
 		boards  []*Board
 
-		mu         sync.RWMutex
+		mu         sync.Mutex
 		silentErrs []error
 	)
 	eg, egctx := errgroup.WithContext(ctx)
 	eg.Go(func() error {
-		res, se, err := readAllBoardsCSV(egctx, mi.cfg.DashboardsFile)
+		res, se, err := mi.cfg.readBoards(egctx)
 		if err != nil {
 			return err
 		}
 		boards = res
 		mu.Lock()
-		silentErrs = se
+		silentErrs = append(silentErrs, se...)
 		mu.Unlock()
 		return nil
 	})
 	eg.Go(func() error {
-		res, se, err := readAllRulesCSV(egctx, mi.cfg.RulesFile)
+		res, se, err := mi.cfg.readRules(egctx)
 		if err != nil {
 			return err
 		}
 		rules = res
 		mu.Lock()
-		silentErrs = se
+		silentErrs = append(silentErrs, se...)
 		mu.Unlock()
 		return nil
 	})
 	eg.Go(func() error {
-		res, err := readAllMetricsCSV(egctx, mi.cfg.MetricsFile)
+		res, se, err := mi.cfg.readMetrics(egctx)
 		if err != nil {
 			return err
 		}
 		metrics = res
+		mu.Lock()
+		silentErrs = append(silentErrs, se...)
+		mu.Unlock()
 		return nil
 	})
 	if err := eg.Wait(); err != nil {
 		return nil, fmt.Errorf("wait eg: %w", err)
 	}
+	vars, err := readVariablesFile(mi.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
 
-	used, se := mi.usedMetricsFrom(boards, rules)
+	used, se := mi.usedMetricsFrom(boards, rules, metrics, vars)
 	if len(se) > 0 {
 		silentErrs = append(silentErrs, se...)
 	}
 
 	var idles []MetricName
-	for m, _ := range metrics {
+	for m := range metrics {
 		if mi.isOffLimit(len(idles)) {
 			break
 		}
@@ -313,31 +381,37 @@ func (mi *MetricsIdler) List(ctx context.Context) (*IdleMetricsResult, error) {
 	}, nil
 }
 
-func (mi *MetricsIdler) usedMetricsFrom(boards []*Board, rules []Rule) (map[MetricName]struct{}, []error) {
+func (mi *MetricsIdler) usedMetricsFrom(
+	boards []*Board,
+	rules []Rule,
+	known Metrics,
+	vars Variables,
+) (map[MetricName]struct{}, []error) {
 	metrics := make(map[MetricName]struct{})
 	var silentErrs []error
 	for _, rule := range rules {
-		ms, err := parsePromQuery(rule.Query)
+		pq, err := parsePromQuery(rule.Query, vars)
 		if err != nil {
 			silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
 			continue
 		}
-		for _, m := range ms {
+		for _, m := range pq.resolve(known) {
 			metrics[m] = struct{}{}
 		}
 	}
 	for _, board := range boards {
-		for _, panel := range board.Panels {
+		bvars := mergeVariables(board.Variables(), vars)
+		for _, panel := range board.AllPanels() {
 			for _, target := range panel.Targets {
 				if target.Expr == "" {
 					continue
 				}
-				ms, err := parsePromQuery(target.Expr)
+				pq, err := parsePromQuery(target.Expr, bvars)
 				if err != nil {
 					silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
 					continue
 				}
-				for _, m := range ms {
+				for _, m := range pq.resolve(known) {
 					metrics[m] = struct{}{}
 				}
 			}
@@ -350,7 +424,15 @@ func (mi *MetricsIdler) isOffLimit(n int) bool {
 	return uint64(n) >= mi.cfg.Limit
 }
 
-func missingValues[T comparable](search map[T]struct{}, vals ...T) ([]T, bool) {
+func toSet[T comparable](vals []T) map[T]struct{} {
+	res := make(map[T]struct{}, len(vals))
+	for _, v := range vals {
+		res[v] = struct{}{}
+	}
+	return res
+}
+
+func missingValues[T comparable, V any](search map[T]V, vals ...T) ([]T, bool) {
 	var res []T
 	for _, v := range vals {
 		if _, ok := search[v]; !ok {
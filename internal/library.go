@@ -0,0 +1,87 @@
+package internal
+
+import (
+	"context"
+	"sort"
+)
+
+type LibraryPanelsConfig struct {
+	*Source
+	Limit uint64
+}
+
+type (
+	LibraryPanelsResult struct {
+		// Panels are the library panels in use, the ones used by the most dashboards first.
+		Panels    []LibraryPanelUsage
+		ParseErrs []error
+	}
+	LibraryPanelUsage struct {
+		UID  string
+		Name string
+		Type string
+		// Resolved is unset for exports of older versions, which lack the library panel models.
+		Resolved bool
+		// Instances is the number of panels using the library panel.
+		Instances  int
+		Dashboards []*Board
+	}
+)
+
+type LibraryPanelsLister struct {
+	cfg *LibraryPanelsConfig
+}
+
+func NewLibraryPanelsLister(cfg *LibraryPanelsConfig) *LibraryPanelsLister {
+	return &LibraryPanelsLister{cfg: cfg}
+}
+
+// List returns every library panel in use along with the dashboards using it, including the panels of collapsed rows.
+func (ll *LibraryPanelsLister) List(ctx context.Context) (*LibraryPanelsResult, error) {
+	boards, silentErrs, err := ll.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]*LibraryPanelUsage)
+	for _, board := range boards {
+		seen := make(map[string]struct{})
+		for _, panel := range board.AllPanels() {
+			if panel.LibraryPanel == nil || panel.LibraryPanel.UID == "" {
+				continue
+			}
+			uid := panel.LibraryPanel.UID
+			lu, ok := usages[uid]
+			if !ok {
+				lu = &LibraryPanelUsage{UID: uid}
+				usages[uid] = lu
+			}
+			if lu.Name == "" {
+				lu.Name = panel.LibraryPanel.Name
+			}
+			if panel.Type != "" {
+				lu.Type, lu.Resolved = panel.Type, true
+			}
+			lu.Instances++
+			if _, ok := seen[uid]; !ok {
+				seen[uid] = struct{}{}
+				lu.Dashboards = append(lu.Dashboards, &Board{UID: board.UID, Title: board.Title, Folder: board.Folder})
+			}
+		}
+	}
+
+	res := make([]LibraryPanelUsage, 0, len(usages))
+	for _, lu := range usages {
+		res = append(res, *lu)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Dashboards) != len(res[j].Dashboards) {
+			return len(res[i].Dashboards) > len(res[j].Dashboards)
+		}
+		return res[i].UID < res[j].UID
+	})
+	return &LibraryPanelsResult{
+		Panels:    res[:min(ll.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
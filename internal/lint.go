@@ -0,0 +1,291 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+type LintConfig struct {
+	*Source
+	VarsFile string
+	// ScrapeInterval is the scrape interval range windows are checked against.
+	ScrapeInterval time.Duration
+}
+
+const (
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityInfo    = "info"
+)
+
+// IDs of the lint checks.
+const (
+	LintRateOnGauge         = "rate-on-gauge"
+	LintCounterWithoutRate  = "counter-without-rate"
+	LintQuantileWithoutLe   = "quantile-without-le"
+	LintQuantileOnNonBucket = "quantile-on-non-bucket"
+	LintIrateInAlert        = "irate-in-alert"
+	LintUnboundedRegex      = "unbounded-regex"
+	LintShortRangeWindow    = "short-range-window"
+)
+
+type (
+	LintResult struct {
+		Findings  []LintFinding
+		ParseErrs []error
+	}
+	// LintFinding is an issue of a rule or a dashboard panel.
+	LintFinding struct {
+		ID       string
+		Severity string
+		Message  string
+		// Expr is the offending part of the query.
+		Expr  string
+		Rule  *Rule
+		Board *Board
+		Panel *Panel
+	}
+)
+
+var (
+	// literalRangeRegex matches the literal durations of range & subquery windows, e.g. [5m] or [1h30m:1m].
+	literalRangeRegex = regexp.MustCompile(`\[\s*((?:\d+(?:ms|[smhdwy]))+)\s*[:\]]`)
+	// unboundedRegexRegex matches regex matchers that match everything, e.g. =~".*".
+	unboundedRegexRegex = regexp.MustCompile("=~\\s*[\"'`]\\.\\*[\"'`]")
+	bucketNameRegex     = regexp.MustCompile(`_bucket\b`)
+	// counterFuncs are the functions & aggregations that make sense over raw counters.
+	counterFuncs = map[string]struct{}{
+		"rate": {}, "irate": {}, "increase": {}, "resets": {}, "changes": {},
+		"absent": {}, "absent_over_time": {}, "present_over_time": {}, "count_over_time": {},
+		"timestamp": {}, "count": {}, "count_values": {}, "group": {},
+	}
+	lintSeverityRank = map[string]int{LintSeverityError: 0, LintSeverityWarning: 1, LintSeverityInfo: 2}
+)
+
+type PromQLLinter struct {
+	cfg *LintConfig
+}
+
+func NewPromQLLinter(cfg *LintConfig) *PromQLLinter {
+	return &PromQLLinter{cfg: cfg}
+}
+
+// Lint checks the queries of all rules & dashboard targets for PromQL anti-patterns,
+// findings are sorted by severity.
+func (pl *PromQLLinter) Lint(ctx context.Context) (*LintResult, error) {
+	metrics, silentErrs, err := pl.cfg.readMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, se, err := pl.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	boards, se, err := pl.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(pl.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	const minSamples = 4
+	ql := &queryLinter{metrics: metrics, minRange: minSamples * pl.cfg.ScrapeInterval}
+	var res []LintFinding
+	for _, rule := range rules {
+		findings, err := ql.lint(rule.Query, nil, rule.Type == "alert")
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("lint rule %q: %w", rule.Name, err))
+			continue
+		}
+		for _, f := range findings {
+			f.Rule = &rule
+			res = append(res, f)
+		}
+	}
+	for _, board := range boards {
+		bvars := mergeVariables(board.Variables(), vars)
+		b := &Board{UID: board.UID, Title: board.Title}
+		for _, panel := range board.AllPanels() {
+			p := &Panel{ID: panel.ID, Title: panel.Title, Type: panel.Type}
+			for _, target := range panel.Targets {
+				if target.Expr == "" {
+					continue
+				}
+				findings, err := ql.lint(target.Expr, bvars, false)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("lint board %q: %w", board.UID, err))
+					continue
+				}
+				for _, f := range findings {
+					f.Board, f.Panel = b, p
+					res = append(res, f)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return lintSeverityRank[res[i].Severity] < lintSeverityRank[res[j].Severity]
+	})
+	return &LintResult{
+		Findings:  res,
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+type queryLinter struct {
+	metrics Metrics
+	// minRange is the shortest range window that is expected to hold enough samples.
+	minRange time.Duration
+}
+
+func (ql *queryLinter) lint(query string, vars Variables, alert bool) ([]LintFinding, error) {
+	expr, err := parseExpr(query, vars)
+	if err != nil {
+		return nil, err
+	}
+	// windows & regexes coming from variables are grafana's business, only literal ones are checked
+	literals := literalRanges(query)
+	unbounded := unboundedRegexRegex.MatchString(query)
+
+	var res []LintFinding
+	add := func(id, severity string, node parser.Node, format string, args ...any) {
+		res = append(res, LintFinding{
+			ID:       id,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+			Expr:     node.String(),
+		})
+	}
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.Call:
+			switch n.Func.Name {
+			case "rate", "irate", "increase":
+				if name := rangeArgName(n); name != "" && ql.metrics.typeOf(MetricName(name)) == string(promapiv1.MetricTypeGauge) {
+					add(LintRateOnGauge, LintSeverityWarning, n, "%s is applied to gauge %s, use deriv or delta instead", n.Func.Name, name)
+				}
+				if n.Func.Name == "irate" && alert {
+					add(LintIrateInAlert, LintSeverityWarning, n, "irate only considers the last two samples, use rate in alerts")
+				}
+			case "histogram_quantile":
+				ql.lintQuantile(n, add)
+			}
+		case *parser.VectorSelector:
+			name := selectorName(n)
+			if name != "" && ql.metrics.typeOf(MetricName(name)) == string(promapiv1.MetricTypeCounter) && !underCounterFunc(path) {
+				add(LintCounterWithoutRate, LintSeverityWarning, n, "counter %s is used without rate or increase", name)
+			}
+			for _, m := range n.LabelMatchers {
+				if unbounded && m.Type == labels.MatchRegexp && m.Value == ".*" {
+					add(LintUnboundedRegex, LintSeverityInfo, n, "%s=~\".*\" matches everything, drop the matcher", m.Name)
+				}
+			}
+		case *parser.MatrixSelector:
+			if _, ok := literals[n.Range]; ok && n.Range < ql.minRange {
+				add(LintShortRangeWindow, LintSeverityWarning, n, "range %s is shorter than %s, 4x the scrape interval",
+					model.Duration(n.Range), model.Duration(ql.minRange))
+			}
+		}
+		return nil
+	})
+	return res, nil
+}
+
+func (ql *queryLinter) lintQuantile(call *parser.Call, add func(id, severity string, node parser.Node, format string, args ...any)) {
+	if len(call.Args) < 2 {
+		return
+	}
+	arg := unwrapParens(call.Args[1])
+	var classic bool
+	parser.Inspect(arg, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		name := selectorName(vs)
+		switch {
+		case name == "":
+		case bucketNameRegex.MatchString(name):
+			classic = true
+		case ql.isKnownNonHistogram(MetricName(name)):
+			add(LintQuantileOnNonBucket, LintSeverityError, call, "histogram_quantile is applied to %s, which is neither a bucket nor a native histogram", name)
+		}
+		return nil
+	})
+	agg, ok := arg.(*parser.AggregateExpr)
+	if !classic || !ok {
+		return
+	}
+	if slices.Contains(agg.Grouping, model.BucketLabel) == agg.Without {
+		add(LintQuantileWithoutLe, LintSeverityError, call, "%s drops the le label histogram_quantile needs", agg.Op)
+	}
+}
+
+// isKnownNonHistogram reports whether the metadata of the metric tells it's no histogram,
+// recording rule outputs & metrics without metadata are unknown.
+func (ql *queryLinter) isKnownNonHistogram(name MetricName) bool {
+	typ := ql.metrics.typeOf(name)
+	return typ != "" && typ != string(promapiv1.MetricTypeHistogram)
+}
+
+func rangeArgName(call *parser.Call) string {
+	if ms, ok := matrixArg(call); ok {
+		if vs, ok := ms.VectorSelector.(*parser.VectorSelector); ok {
+			return selectorName(vs)
+		}
+	}
+	return ""
+}
+
+func underCounterFunc(path []parser.Node) bool {
+	for _, node := range path {
+		switch n := node.(type) {
+		case *parser.Call:
+			if _, ok := counterFuncs[n.Func.Name]; ok {
+				return true
+			}
+		case *parser.AggregateExpr:
+			if _, ok := counterFuncs[n.Op.String()]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unwrapParens(expr parser.Expr) parser.Expr {
+	for {
+		switch e := expr.(type) {
+		case *parser.ParenExpr:
+			expr = e.Expr
+		case *parser.StepInvariantExpr:
+			expr = e.Expr
+		default:
+			return expr
+		}
+	}
+}
+
+func literalRanges(query string) map[time.Duration]struct{} {
+	res := make(map[time.Duration]struct{})
+	for _, sub := range literalRangeRegex.FindAllStringSubmatch(query, -1) {
+		if d, err := model.ParseDuration(sub[1]); err == nil {
+			res[time.Duration(d)] = struct{}{}
+		}
+	}
+	return res
+}
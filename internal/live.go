@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"golang.org/x/sync/errgroup"
+)
+
+// LiveConfig enables validating selectors against a running prometheus.
+type LiveConfig struct {
+	Addr        string
+	Concurrency int
+}
+
+// selectorValidator finds selectors that don't match any series at the moment.
+type selectorValidator struct {
+	cfg   *LiveConfig
+	v1api promapiv1.API
+}
+
+func newSelectorValidator(cfg *LiveConfig) *selectorValidator {
+	return &selectorValidator{
+		cfg:   cfg,
+		v1api: mustNewPromAPIV1(cfg.Addr),
+	}
+}
+
+// emptySelectors runs a count() of each distinct selector & returns the ones without any series.
+func (sv *selectorValidator) emptySelectors(ctx context.Context, selectors []string) (map[string]struct{}, []error) {
+	var (
+		mu         sync.Mutex
+		empties    = make(map[string]struct{})
+		silentErrs []error
+		now        = time.Now()
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(sv.cfg.Concurrency, 1))
+	for _, sel := range distinct(selectors) {
+		eg.Go(func() error {
+			val, _, err := sv.v1api.Query(egctx, fmt.Sprintf("count(%s)", sel), now)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("query %s: %w", sel, err))
+				return nil
+			}
+			if vec, ok := val.(model.Vector); ok && len(vec) == 0 {
+				empties[sel] = struct{}{}
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	return empties, silentErrs
+}
+
+// liveSelectors returns the selectors of the query worth validating live,
+// the ones whose metrics are already known to be missing are skipped.
+func liveSelectors(pq *promQuery, missing map[MetricName]struct{}) []string {
+	res := make([]string, 0, len(pq.selectors))
+	for _, sel := range pq.selectors {
+		if _, ok := missing[MetricName(selectorName(sel))]; ok {
+			continue
+		}
+		res = append(res, sel.String())
+	}
+	return res
+}
+
+func selectorName(sel *parser.VectorSelector) string {
+	if sel.Name != "" {
+		return sel.Name
+	}
+	for _, m := range sel.LabelMatchers {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+			return m.Value
+		}
+	}
+	return ""
+}
+
+func distinct[T comparable](vals []T) []T {
+	seen := make(map[T]struct{}, len(vals))
+	res := make([]T, 0, len(vals))
+	for _, v := range vals {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		res = append(res, v)
+	}
+	return res
+}
+
+func filterIn[T comparable](vals []T, set map[T]struct{}) []T {
+	var res []T
+	for _, v := range vals {
+		if _, ok := set[v]; ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSelectorValidatorEmptySelectors(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries = make(map[string]int)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		query := r.Form.Get("query")
+		mu.Lock()
+		queries[query]++
+		mu.Unlock()
+		switch query {
+		case `count(up{job="api"})`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1731189600,"3"]}]}}`))
+		case `count(up{job="typo"})`:
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"execution","error":"query timed out"}`))
+		}
+	}))
+	defer srv.Close()
+
+	sv := newSelectorValidator(&LiveConfig{Addr: srv.URL, Concurrency: 2})
+	selectors := []string{`up{job="api"}`, `up{job="typo"}`, `slow_metric`, `up{job="typo"}`}
+	empties, errs := sv.emptySelectors(context.Background(), selectors)
+
+	if _, ok := empties[`up{job="typo"}`]; !ok || len(empties) != 1 {
+		t.Errorf("got empty selectors %v, want only up{job=\"typo\"}", empties)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "query slow_metric") {
+		t.Errorf("got errors %v, want one of slow_metric", errs)
+	}
+	// selectors are queried once
+	if c := queries[`count(up{job="typo"})`]; c != 1 {
+		t.Errorf("got %d queries of the duplicate selector, want 1", c)
+	}
+}
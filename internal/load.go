@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+type LoadConfig struct {
+	*Source
+	// MinRefresh is the shortest auto refresh interval not considered aggressive.
+	MinRefresh time.Duration
+	// LongRange is the time range from which on refreshing faster than 10x MinRefresh is considered aggressive.
+	LongRange        time.Duration
+	MaxQueriesPerMin float64
+	// ScrapeInterval is the query step of panels without an interval.
+	ScrapeInterval time.Duration
+	Limit          uint64
+}
+
+// IDs of the load checks.
+const (
+	LoadShortRefresh     = "short-refresh"
+	LoadLongRangeRefresh = "long-range-refresh"
+	LoadHighQueryRate    = "high-query-rate"
+	LoadStepsOverLimit   = "steps-over-limit"
+)
+
+// defaultMaxDataPoints approximates the width in pixels grafana uses when a panel doesn't set max data points.
+const defaultMaxDataPoints = 1000
+
+type (
+	LoadResult struct {
+		Loads     []BoardLoad
+		ParseErrs []error
+	}
+	// BoardLoad is the query load a dashboard puts on prometheus while open with its default settings.
+	BoardLoad struct {
+		Board   *Board
+		Refresh time.Duration
+		Range   time.Duration
+		// Queries is the number of queries of a single refresh.
+		Queries       int
+		QueriesPerMin float64
+		StepsPerQuery float64
+		// StepsPerMin is the number of steps evaluated per minute, which the loads are ranked by.
+		StepsPerMin float64
+		Checks      []string
+	}
+)
+
+type DashboardsLoadEstimator struct {
+	cfg *LoadConfig
+}
+
+func NewDashboardsLoadEstimator(cfg *LoadConfig) *DashboardsLoadEstimator {
+	return &DashboardsLoadEstimator{cfg: cfg}
+}
+
+// Estimate derives the queries per minute from the refresh interval & the steps per query
+// from the default time range, the panel intervals & max data points of every dashboard.
+func (le *DashboardsLoadEstimator) Estimate(ctx context.Context) (*LoadResult, error) {
+	boards, silentErrs, err := le.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var res []BoardLoad
+	for _, board := range boards {
+		bl := BoardLoad{Board: &Board{UID: board.UID, Title: board.Title, Refresh: board.Refresh, Time: board.Time}}
+		if board.Refresh != "" {
+			refresh, err := model.ParseDuration(board.Refresh)
+			if err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("parse refresh of %q: %w", board.UID, err))
+			}
+			bl.Refresh = time.Duration(refresh)
+		}
+		// grafana's default time range is the last 6 hours, exports of older versions lack it
+		tr := board.Time
+		if tr.From == "" {
+			tr.From = "now-6h"
+		}
+		if tr.To == "" {
+			tr.To = "now"
+		}
+		from, err := parseGrafanaTime(tr.From, now)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse time from of %q: %w", board.UID, err))
+			continue
+		}
+		to, err := parseGrafanaTime(tr.To, now)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse time to of %q: %w", board.UID, err))
+			continue
+		}
+		bl.Range = to.Sub(from)
+
+		var steps float64
+		// panels of collapsed rows aren't queried until the row is expanded, they add no load
+		for _, panel := range board.Panels {
+			ps := le.panelSteps(panel, bl.Range)
+			for _, target := range panel.Targets {
+				// hidden queries aren't run
+				if target.Expr == "" || target.Hide {
+					continue
+				}
+				bl.Queries++
+				steps += ps
+			}
+			if ps > maxQueryPoints {
+				bl.Checks = append(bl.Checks, LoadStepsOverLimit)
+			}
+		}
+		if bl.Queries == 0 {
+			continue
+		}
+		bl.StepsPerQuery = steps / float64(bl.Queries)
+		if bl.Refresh > 0 {
+			bl.QueriesPerMin = float64(bl.Queries) * float64(time.Minute) / float64(bl.Refresh)
+			bl.StepsPerMin = bl.QueriesPerMin * bl.StepsPerQuery
+		}
+		bl.Checks = distinct(append(bl.Checks, le.check(&bl)...))
+		res = append(res, bl)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].StepsPerMin != res[j].StepsPerMin {
+			return res[i].StepsPerMin > res[j].StepsPerMin
+		}
+		return res[i].Queries > res[j].Queries
+	})
+	return &LoadResult{
+		Loads:     res[:min(le.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+// panelSteps is the number of steps of the panel's queries, their step being the time range
+// divided by max data points, not less than the panel's interval.
+func (le *DashboardsLoadEstimator) panelSteps(panel *Panel, rng time.Duration) float64 {
+	maxPoints := panel.MaxDataPoints
+	if maxPoints == 0 {
+		maxPoints = defaultMaxDataPoints
+	}
+	step := le.cfg.ScrapeInterval
+	// intervals of older dashboards are written as >10s, variables can't be resolved
+	if d, err := model.ParseDuration(strings.TrimPrefix(panel.Interval, ">")); err == nil {
+		step = time.Duration(d)
+	}
+	step = max(step, rng/time.Duration(maxPoints), time.Second)
+	return float64(rng) / float64(step)
+}
+
+func (le *DashboardsLoadEstimator) check(bl *BoardLoad) []string {
+	if bl.Refresh <= 0 {
+		return nil
+	}
+	var res []string
+	if bl.Refresh < le.cfg.MinRefresh {
+		res = append(res, LoadShortRefresh)
+	}
+	if bl.Range >= le.cfg.LongRange && bl.Refresh < 10*le.cfg.MinRefresh {
+		res = append(res, LoadLongRangeRefresh)
+	}
+	if le.cfg.MaxQueriesPerMin > 0 && bl.QueriesPerMin > le.cfg.MaxQueriesPerMin {
+		res = append(res, LoadHighQueryRate)
+	}
+	return res
+}
+
+// parseGrafanaTime parses relative times like now-7d or now-1d/d, rounding is ignored,
+// as well as absolute ones in RFC 3339 or epoch milliseconds.
+func parseGrafanaTime(s string, now time.Time) (time.Time, error) {
+	if rel, ok := strings.CutPrefix(s, "now"); ok {
+		rel, _, _ = strings.Cut(rel, "/")
+		if rel == "" {
+			return now, nil
+		}
+		sign := time.Duration(1)
+		switch rel[0] {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			return time.Time{}, fmt.Errorf("invalid relative time %q", s)
+		}
+		d, err := model.ParseDuration(rel[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse relative time %q: %w", s, err)
+		}
+		return now.Add(sign * time.Duration(d)), nil
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse time %q: %w", s, err)
+	}
+	return t, nil
+}
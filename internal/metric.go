@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 
-	"github.com/prometheus/common/model"
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
 type (
@@ -15,7 +17,35 @@ type (
 	MetricName  string
 )
 
-func writeAllMetricsCSV(ctx context.Context, file string, metrics model.LabelValues) error {
+type (
+	// Metric is an exported prometheus metric.
+	Metric struct {
+		Name MetricName `json:"name"`
+		// Series is the number of head series, only known for the metrics with the most series.
+		Series uint64 `json:"series,omitempty"`
+		// Type, Help & Unit come from the metadata prometheus keeps of the scraped metric families.
+		Type string `json:"type,omitempty"`
+		Help string `json:"help,omitempty"`
+		Unit string `json:"unit,omitempty"`
+	}
+	// Metrics are exported metrics by their names.
+	Metrics map[MetricName]*Metric
+)
+
+type colMetric uint8
+
+const (
+	colMetricName colMetric = iota
+	colMetricSeries
+	colMetricType
+	colMetricHelp
+	colMetricUnit
+	colMetricNum
+)
+
+var metricHeaders = [colMetricNum]string{"name", "series", "type", "help", "unit"}
+
+func writeAllMetricsCSV(ctx context.Context, file string, metrics []*Metric) error {
 	f, err := os.Create(file)
 	if err != nil {
 		return fmt.Errorf("create output file: %w", err)
@@ -24,31 +54,33 @@ func writeAllMetricsCSV(ctx context.Context, file string, metrics model.LabelVal
 		_ = f.Close()
 	}()
 
-	const batchSize, numCol = 100, 1
+	const batchSize = 100
 	wr := &csvBatchWriter{
 		size: batchSize,
-		buf:  make([]string, numCol),
+		buf:  make([]string, colMetricNum),
 		w:    csv.NewWriter(f),
 	}
 	err = wr.Write(ctx, func(buf []string) {
-		buf[0] = "name"
+		copy(buf, metricHeaders[:])
 	})
 	if err != nil {
 		return fmt.Errorf("write headers: %w", err)
 	}
 	for _, metric := range metrics {
 		err = wr.Write(ctx, func(buf []string) {
-			buf[0] = string(metric)
+			buf[colMetricName] = string(metric.Name)
+			buf[colMetricSeries] = strconv.FormatUint(metric.Series, 10)
+			buf[colMetricType], buf[colMetricHelp], buf[colMetricUnit] = metric.Type, metric.Help, metric.Unit
 		})
 		if err != nil {
 			return err
 		}
 	}
 	wr.Flush()
-	return nil
+	return wr.w.Error()
 }
 
-func readAllMetricsCSV(ctx context.Context, file string) (map[MetricName]struct{}, error) {
+func readAllMetricsCSV(ctx context.Context, file string) (Metrics, error) {
 	mf, err := os.Open(file)
 	if err != nil {
 		return nil, fmt.Errorf("open metrics: %w", err)
@@ -57,10 +89,11 @@ func readAllMetricsCSV(ctx context.Context, file string) (map[MetricName]struct{
 		_ = mf.Close()
 	}()
 
-	metrics := make(map[MetricName]struct{})
+	metrics := make(Metrics)
 	mr := csv.NewReader(mf)
-	if _, err = mr.Read(); err != nil { // reading header
-		return nil, fmt.Errorf("read header: %w", err)
+	h, err := readCSVHeader(mr)
+	if err != nil {
+		return nil, err
 	}
 
 OUT:
@@ -76,8 +109,48 @@ OUT:
 			if err != nil {
 				return nil, fmt.Errorf("read metric: %w", err)
 			}
-			metrics[MetricName(rec[0])] = struct{}{}
+			m := &Metric{
+				Name: MetricName(h.get(rec, metricHeaders[colMetricName])),
+				Type: h.get(rec, metricHeaders[colMetricType]),
+				Help: h.get(rec, metricHeaders[colMetricHelp]),
+				Unit: h.get(rec, metricHeaders[colMetricUnit]),
+			}
+			if s := h.get(rec, metricHeaders[colMetricSeries]); s != "" {
+				if m.Series, err = strconv.ParseUint(s, 10, 64); err != nil {
+					return nil, fmt.Errorf("parse series of %q: %w", m.Name, err)
+				}
+			}
+			metrics[m.Name] = m
 		}
 	}
 	return metrics, nil
 }
+
+func metricsFrom(list []*Metric) Metrics {
+	res := make(Metrics, len(list))
+	for _, m := range list {
+		res[m.Name] = m
+	}
+	return res
+}
+
+// typeOf returns the type of a series name. The sums & counts of histograms & summaries are reported as counters,
+// metrics without metadata ending with _total are assumed to be counters.
+func (ms Metrics) typeOf(name MetricName) string {
+	var typ string
+	if m, ok := ms[name]; ok {
+		typ = m.Type
+	}
+	switch promapiv1.MetricType(typ) {
+	case promapiv1.MetricTypeHistogram, promapiv1.MetricTypeGaugeHistogram, promapiv1.MetricTypeSummary:
+		if strings.HasSuffix(string(name), "_count") || strings.HasSuffix(string(name), "_sum") {
+			return string(promapiv1.MetricTypeCounter)
+		}
+	case "", promapiv1.MetricTypeUnknown:
+		if strings.HasSuffix(string(name), "_total") {
+			return string(promapiv1.MetricTypeCounter)
+		}
+		return ""
+	}
+	return typ
+}
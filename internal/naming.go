@@ -0,0 +1,376 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+type NamingConfig struct {
+	*Source
+	VarsFile string
+}
+
+// IDs of the naming checks.
+const (
+	LintRecordingRuleName = "recording-rule-name"
+	LintCounterTotal      = "counter-total-suffix"
+	LintNonCounterTotal   = "non-counter-total-suffix"
+	LintUnitSuffix        = "unit-suffix"
+	LintBaseUnit          = "base-unit"
+)
+
+const (
+	RenameKindRule   = "rule"
+	RenameKindMetric = "metric"
+)
+
+type (
+	NamingResult struct {
+		Renames   []Rename
+		ParseErrs []error
+	}
+	// Rename is a recording rule or a metric not following the naming conventions,
+	// along with the rules & dashboards that would need updating.
+	Rename struct {
+		Kind      string
+		Name      string
+		Suggested string
+		Checks    []string
+		Rules     []Rule
+		// Dashboards hold the affected panels only.
+		Dashboards []*Board
+	}
+)
+
+var (
+	// recordingRuleNameRegex matches the level:metric:operations convention.
+	recordingRuleNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*:[a-zA-Z_][a-zA-Z0-9_]*:[a-zA-Z0-9_]+$`)
+	nonBaseUnits           = []struct {
+		re   *regexp.Regexp
+		base string
+	}{
+		{regexp.MustCompile(`_(nanoseconds|microseconds|milliseconds|ms|minutes|hours|days)(_|$)`), "seconds"},
+		{regexp.MustCompile(`_(kilobytes|megabytes|gigabytes|kb|mb|gb)(_|$)`), "bytes"},
+		{regexp.MustCompile(`_(percent|percentage)(_|$)`), "ratio"},
+	}
+)
+
+type NamingLinter struct {
+	cfg *NamingConfig
+}
+
+func NewNamingLinter(cfg *NamingConfig) *NamingLinter {
+	return &NamingLinter{cfg: cfg}
+}
+
+// Lint checks the names of recording rules & metrics, suggests conforming ones
+// & lists the rules and dashboard panels referencing them.
+func (nl *NamingLinter) Lint(ctx context.Context) (*NamingResult, error) {
+	metrics, silentErrs, err := nl.cfg.readMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, se, err := nl.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	boards, se, err := nl.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(nl.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var renames []Rename
+	seen := make(map[string]struct{})
+	for _, rule := range rules {
+		if rule.Type != "record" || recordingRuleNameRegex.MatchString(rule.Name) {
+			continue
+		}
+		if _, ok := seen[rule.Name]; ok {
+			continue
+		}
+		seen[rule.Name] = struct{}{}
+		suggested, err := suggestRecordingName(rule)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("suggest name of %q: %w", rule.Name, err))
+		}
+		renames = append(renames, Rename{
+			Kind:      RenameKindRule,
+			Name:      rule.Name,
+			Suggested: suggested,
+			Checks:    []string{LintRecordingRuleName},
+		})
+	}
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, string(name))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if _, ok := seen[name]; ok || strings.Contains(name, ":") {
+			continue
+		}
+		if suggested, checks := suggestMetricName(metrics[MetricName(name)]); len(checks) > 0 {
+			renames = append(renames, Rename{
+				Kind:      RenameKindMetric,
+				Name:      name,
+				Suggested: suggested,
+				Checks:    checks,
+			})
+		}
+	}
+
+	usages, se := nameUsagesOf(rules, boards, metrics, vars)
+	silentErrs = append(silentErrs, se...)
+	for i := range renames {
+		name := MetricName(renames[i].Name)
+		renames[i].Rules = usages.rules[name]
+		renames[i].Dashboards = usages.boards[name]
+	}
+	return &NamingResult{
+		Renames:   renames,
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+// suggestRecordingName derives a level:metric:operations name from the rule's query:
+// the level from the grouping labels of the outermost aggregation, the metric from the first selector
+// & the operations, newest first, from the functions & aggregations applied to it.
+func suggestRecordingName(rule Rule) (string, error) {
+	expr, err := parseExpr(rule.Query, nil)
+	if err != nil {
+		return "", err
+	}
+	current := strings.Split(rule.Name, ":")
+
+	var (
+		level, metric string
+		ops           []string
+	)
+	var agg *parser.AggregateExpr
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if n, ok := node.(*parser.AggregateExpr); ok && agg == nil {
+			agg = n
+		}
+		return nil
+	})
+	var grouping []string
+	if agg != nil && !agg.Without {
+		// le only survives the aggregation for histogram_quantile
+		grouping = slices.DeleteFunc(slices.Clone(agg.Grouping), func(l string) bool { return l == model.BucketLabel })
+		slices.Sort(grouping)
+	}
+	switch {
+	case len(grouping) > 0:
+		level = strings.Join(grouping, "_")
+	case len(current) > 1 && current[0] != "":
+		level = current[0]
+	case agg != nil:
+		level = "cluster"
+	default:
+		level = "instance"
+	}
+
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok || metric != "" {
+			return nil
+		}
+		name := selectorName(vs)
+		if name == "" {
+			return nil
+		}
+		metric = name
+		if parts := strings.Split(name, ":"); len(parts) == 3 {
+			metric = parts[1]
+		}
+		ops = operationsOf(path)
+		if slices.ContainsFunc(path, isRateCall) {
+			metric = strings.TrimSuffix(metric, "_total")
+		}
+		if slices.ContainsFunc(path, isQuantileCall) {
+			metric = strings.TrimSuffix(metric, "_bucket")
+		}
+		return nil
+	})
+	if metric == "" {
+		return "", fmt.Errorf("no metric selector")
+	}
+	if len(ops) == 0 && len(current) == 3 && current[2] != "" {
+		ops = []string{current[2]}
+	}
+	if len(ops) == 0 {
+		return "", fmt.Errorf("no operations")
+	}
+	return strings.Join([]string{level, metric, strings.Join(ops, "_")}, ":"), nil
+}
+
+// operationsOf returns the operations applied by the ancestors of a selector, newest first.
+// A sum is only named when it's the only operation.
+func operationsOf(path []parser.Node) []string {
+	var (
+		ops    []string
+		summed bool
+	)
+	for _, node := range path {
+		switch n := node.(type) {
+		case *parser.Call:
+			switch {
+			case isQuantileCall(n):
+				if q, ok := unwrapParens(n.Args[0]).(*parser.NumberLiteral); ok {
+					ops = append(ops, "p"+strings.ReplaceAll(strconv.FormatFloat(q.Val*100, 'f', -1, 64), ".", ""))
+				}
+			default:
+				op := n.Func.Name
+				if ms, ok := matrixArg(n); ok {
+					op += model.Duration(ms.Range).String()
+				}
+				ops = append(ops, op)
+			}
+		case *parser.AggregateExpr:
+			if n.Op == parser.SUM {
+				summed = true
+				continue
+			}
+			ops = append(ops, n.Op.String())
+		case *parser.BinaryExpr:
+			if n.Op == parser.DIV {
+				ops = append(ops, "ratio")
+			}
+		}
+	}
+	if len(ops) == 0 && summed {
+		ops = append(ops, "sum")
+	}
+	return ops
+}
+
+func matrixArg(call *parser.Call) (*parser.MatrixSelector, bool) {
+	for _, arg := range call.Args {
+		if ms, ok := unwrapParens(arg).(*parser.MatrixSelector); ok {
+			return ms, true
+		}
+	}
+	return nil, false
+}
+
+func isRateCall(node parser.Node) bool {
+	call, ok := node.(*parser.Call)
+	return ok && (call.Func.Name == "rate" || call.Func.Name == "irate" || call.Func.Name == "increase")
+}
+
+func isQuantileCall(node parser.Node) bool {
+	call, ok := node.(*parser.Call)
+	return ok && call.Func.Name == "histogram_quantile" && len(call.Args) == 2
+}
+
+// suggestMetricName checks the metric for base units, unit suffixes & the _total suffix of counters.
+// The series suffixes of histograms & summaries are kept as they are.
+func suggestMetricName(m *Metric) (string, []string) {
+	name, suffix := string(m.Name), ""
+	switch promapiv1.MetricType(m.Type) {
+	case promapiv1.MetricTypeHistogram, promapiv1.MetricTypeGaugeHistogram, promapiv1.MetricTypeSummary:
+		for _, s := range []string{"_bucket", "_count", "_sum"} {
+			if family, ok := strings.CutSuffix(name, s); ok {
+				name, suffix = family, s
+				break
+			}
+		}
+	}
+
+	var checks []string
+	for _, nb := range nonBaseUnits {
+		if nb.re.MatchString(name) {
+			name = nb.re.ReplaceAllString(name, "_"+nb.base+"${2}")
+			checks = append(checks, LintBaseUnit)
+		}
+	}
+	if m.Unit != "" && !slices.Contains(strings.Split(name, "_"), m.Unit) {
+		base, total := strings.CutSuffix(name, "_total")
+		name = base + "_" + m.Unit
+		if total {
+			name += "_total"
+		}
+		checks = append(checks, LintUnitSuffix)
+	}
+	switch promapiv1.MetricType(m.Type) {
+	case promapiv1.MetricTypeCounter:
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+			checks = append(checks, LintCounterTotal)
+		}
+	case promapiv1.MetricTypeGauge:
+		if base, ok := strings.CutSuffix(name, "_total"); ok {
+			name = base
+			checks = append(checks, LintNonCounterTotal)
+		}
+	}
+	return name + suffix, distinct(checks)
+}
+
+// nameUsages are the rules & dashboards referencing metric names.
+type nameUsages struct {
+	rules  map[MetricName][]Rule
+	boards map[MetricName][]*Board
+}
+
+func nameUsagesOf(rules []Rule, boards []*Board, metrics Metrics, vars Variables) (*nameUsages, []error) {
+	var (
+		silentErrs []error
+		res        = &nameUsages{
+			rules:  make(map[MetricName][]Rule),
+			boards: make(map[MetricName][]*Board),
+		}
+	)
+	for _, rule := range rules {
+		pq, err := parsePromQuery(rule.Query, nil)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse prom expr: %w", err))
+			continue
+		}
+		for _, name := range distinct(pq.resolve(metrics)) {
+			res.rules[name] = append(res.rules[name], Rule{Group: rule.Group, Type: rule.Type, Name: rule.Name})
+		}
+	}
+	for _, board := range boards {
+		bvars := mergeVariables(board.Variables(), vars)
+		affected := make(map[MetricName]*Board)
+		for _, panel := range board.AllPanels() {
+			var names MetricNames
+			for _, target := range panel.Targets {
+				if target.Expr == "" {
+					continue
+				}
+				pq, err := parsePromQuery(target.Expr, bvars)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
+					continue
+				}
+				names = append(names, pq.resolve(metrics)...)
+			}
+			for _, name := range distinct(names) {
+				b, ok := affected[name]
+				if !ok {
+					b = &Board{UID: board.UID, Title: board.Title}
+					affected[name] = b
+					res.boards[name] = append(res.boards[name], b)
+				}
+				b.Panels = append(b.Panels, &Panel{ID: panel.ID, Title: panel.Title})
+			}
+		}
+	}
+	return res, silentErrs
+}
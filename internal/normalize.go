@@ -0,0 +1,61 @@
+package internal
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// normalizeQuery parses the query & returns its canonical form, see normalizeExpr.
+func normalizeQuery(query string, vars Variables) (string, error) {
+	expr, err := parseExpr(query, vars)
+	if err != nil {
+		return "", err
+	}
+	return normalizeExpr(expr), nil
+}
+
+// normalizeExpr rewrites the expression in place into a canonical form & formats it,
+// so that queries differing only in whitespace, matcher or grouping label order
+// or the operand order of + & * are formatted the same.
+func normalizeExpr(expr parser.Expr) string {
+	normalizeNode(expr)
+	return expr.String()
+}
+
+func normalizeNode(node parser.Node) {
+	// children first, operands are ordered by their normalized form
+	for _, child := range parser.Children(node) {
+		normalizeNode(child)
+	}
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		slices.SortFunc(n.LabelMatchers, compareMatchers)
+	case *parser.AggregateExpr:
+		slices.Sort(n.Grouping)
+	case *parser.BinaryExpr:
+		if vm := n.VectorMatching; vm != nil {
+			slices.Sort(vm.MatchingLabels)
+			slices.Sort(vm.Include)
+		}
+		// the result takes the labels of the left hand side, operands are only swapped when both sides match on all labels
+		commutative := n.Op == parser.ADD || n.Op == parser.MUL
+		matchesAll := n.VectorMatching == nil ||
+			(n.VectorMatching.Card == parser.CardOneToOne && !n.VectorMatching.On && len(n.VectorMatching.MatchingLabels) == 0)
+		if commutative && matchesAll && n.LHS.String() > n.RHS.String() {
+			n.LHS, n.RHS = n.RHS, n.LHS
+		}
+	}
+}
+
+func compareMatchers(a, b *labels.Matcher) int {
+	if c := strings.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	if a.Type != b.Type {
+		return int(a.Type) - int(b.Type)
+	}
+	return strings.Compare(a.Value, b.Value)
+}
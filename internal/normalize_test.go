@@ -0,0 +1,36 @@
+package internal
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		name, a, b string
+		same       bool
+	}{
+		{name: "whitespace & matcher order", a: `up{job="a",env="b"}`, b: `up{ env="b", job="a" }`, same: true},
+		{name: "grouping order", a: `sum by (b, a) (x)`, b: `sum by (a, b) (x)`, same: true},
+		{name: "add operands", a: `a + b`, b: `b + a`, same: true},
+		{name: "mul operands", a: `a * b`, b: `b * a`, same: true},
+		{name: "sub operands", a: `a - b`, b: `b - a`},
+		{name: "add on labels", a: `a + on(x) b`, b: `b + on(x) a`},
+		{name: "add on no labels", a: `a + on() b`, b: `b + on() a`},
+		{name: "add ignoring labels", a: `a + ignoring(x) b`, b: `b + ignoring(x) a`},
+		{name: "matching label order", a: `a + on(y, x) b`, b: `a + on(x, y) b`, same: true},
+		{name: "group left", a: `a * on(x) group_left b`, b: `b * on(x) group_left a`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := normalizeQuery(tt.a, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := normalizeQuery(tt.b, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (a == b) != tt.same {
+				t.Errorf("got %q & %q, want same %v", a, b, tt.same)
+			}
+		})
+	}
+}
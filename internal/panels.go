@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"context"
+	"sort"
+)
+
+type PanelsConfig struct {
+	*Source
+	// Limit applies to the blocking dashboards.
+	Limit uint64
+}
+
+type (
+	PanelsResult struct {
+		// Types are all panel types in use, the most used first.
+		Types []PanelTypeUsage
+		// Folders hold the deprecated panels per folder.
+		Folders []FolderPanels
+		// Blocking are the dashboards with deprecated panels, which block upgrading grafana.
+		Blocking  []BlockingBoard
+		ParseErrs []error
+	}
+	PanelTypeUsage struct {
+		Type string
+		// Deprecated is set for angular panel types, which newer grafana versions don't render anymore.
+		Deprecated  bool
+		Replacement string
+		Panels      int
+		Dashboards  int
+	}
+	FolderPanels struct {
+		Folder     string
+		Dashboards int
+		Panels     int
+		Types      map[string]int
+	}
+	// BlockingBoard is a dashboard holding only its deprecated panels.
+	BlockingBoard struct {
+		Board *Board
+		Types map[string]int
+	}
+)
+
+// generalFolder is the folder of dashboards that aren't in any.
+const generalFolder = "General"
+
+// deprecatedPanelTypes maps angular panel types to the types replacing them.
+var deprecatedPanelTypes = map[string]string{
+	"graph":                           "timeseries",
+	"singlestat":                      "stat",
+	"grafana-singlestat-panel":        "stat",
+	"table-old":                       "table",
+	"grafana-worldmap-panel":          "geomap",
+	"grafana-piechart-panel":          "piechart",
+	"natel-discrete-panel":            "state-timeline",
+	"natel-plotly-panel":              "",
+	"vonage-status-panel":             "stat",
+	"briangann-gauge-panel":           "gauge",
+	"briangann-datatable-panel":       "table",
+	"btplc-status-dot-panel":          "",
+	"savantly-heatmap-panel":          "heatmap",
+	"neocat-cal-heatmap-panel":        "heatmap",
+	"michaeldmoore-annunciator-panel": "stat",
+	"michaeldmoore-multistat-panel":   "",
+	"jdbranham-diagram-panel":         "canvas",
+	"agenty-flowcharting-panel":       "canvas",
+	"mxswat-separator-panel":          "text",
+	"snuids-trafficlights-panel":      "",
+}
+
+type PanelsInventory struct {
+	cfg *PanelsConfig
+}
+
+func NewPanelsInventory(cfg *PanelsConfig) *PanelsInventory {
+	return &PanelsInventory{cfg: cfg}
+}
+
+// List counts the panel types of all dashboards, including the panels of collapsed rows,
+// and lists the folders & dashboards holding deprecated ones.
+func (pi *PanelsInventory) List(ctx context.Context) (*PanelsResult, error) {
+	boards, silentErrs, err := pi.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		types    = make(map[string]*PanelTypeUsage)
+		folders  = make(map[string]*FolderPanels)
+		blocking []BlockingBoard
+	)
+	for _, board := range boards {
+		bb := BlockingBoard{
+			Board: &Board{UID: board.UID, Title: board.Title, Folder: board.Folder, FolderUID: board.FolderUID},
+			Types: make(map[string]int),
+		}
+		seen := make(map[string]struct{})
+		for _, panel := range board.AllPanels() {
+			if panel.Type == "row" {
+				continue
+			}
+			tu, ok := types[panel.Type]
+			if !ok {
+				replacement, deprecated := deprecatedPanelTypes[panel.Type]
+				tu = &PanelTypeUsage{Type: panel.Type, Deprecated: deprecated, Replacement: replacement}
+				types[panel.Type] = tu
+			}
+			tu.Panels++
+			if _, ok := seen[panel.Type]; !ok {
+				seen[panel.Type] = struct{}{}
+				tu.Dashboards++
+			}
+			if tu.Deprecated {
+				bb.Board.Panels = append(bb.Board.Panels, &Panel{ID: panel.ID, Title: panel.Title, Type: panel.Type})
+				bb.Types[panel.Type]++
+			}
+		}
+		if len(bb.Board.Panels) == 0 {
+			continue
+		}
+		blocking = append(blocking, bb)
+
+		folder := board.Folder
+		if folder == "" {
+			folder = generalFolder
+		}
+		fp, ok := folders[folder]
+		if !ok {
+			fp = &FolderPanels{Folder: folder, Types: make(map[string]int)}
+			folders[folder] = fp
+		}
+		fp.Dashboards++
+		fp.Panels += len(bb.Board.Panels)
+		for typ, n := range bb.Types {
+			fp.Types[typ] += n
+		}
+	}
+
+	res := &PanelsResult{
+		Blocking:  blocking,
+		ParseErrs: silentErrs,
+	}
+	for _, tu := range types {
+		res.Types = append(res.Types, *tu)
+	}
+	sort.Slice(res.Types, func(i, j int) bool {
+		if res.Types[i].Panels != res.Types[j].Panels {
+			return res.Types[i].Panels > res.Types[j].Panels
+		}
+		return res.Types[i].Type < res.Types[j].Type
+	})
+	for _, fp := range folders {
+		res.Folders = append(res.Folders, *fp)
+	}
+	sort.Slice(res.Folders, func(i, j int) bool {
+		if res.Folders[i].Panels != res.Folders[j].Panels {
+			return res.Folders[i].Panels > res.Folders[j].Panels
+		}
+		return res.Folders[i].Folder < res.Folders[j].Folder
+	})
+	sort.SliceStable(res.Blocking, func(i, j int) bool {
+		return len(res.Blocking[i].Board.Panels) > len(res.Blocking[j].Board.Panels)
+	})
+	res.Blocking = res.Blocking[:min(pi.cfg.Limit, uint64(len(res.Blocking)))]
+	return res, nil
+}
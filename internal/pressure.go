@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+type GroupPressureConfig struct {
+	*Source
+	// Threshold is the ratio of evaluation time to interval from which on a group is under pressure.
+	Threshold float64
+	// LagFactor is the number of intervals the last evaluation of a group may lag behind.
+	LagFactor float64
+	Limit     uint64
+}
+
+const (
+	GroupIssueSaturated = "saturated"
+	GroupIssuePressured = "near-saturation"
+	GroupIssueLagging   = "lagging"
+)
+
+type (
+	GroupPressureResult struct {
+		Groups    []GroupPressure
+		ParseErrs []error
+	}
+	GroupPressure struct {
+		Group    string
+		Interval time.Duration
+		EvalTime time.Duration
+		LastEval time.Time
+		// Lag is how far the last evaluation is behind the most recent evaluation across all groups.
+		Lag time.Duration
+		// Ratio is the evaluation time to interval ratio.
+		Ratio  float64
+		Issues []string
+		// SuggestedSplits is the number of groups the rules should be split into to stay below the threshold.
+		SuggestedSplits int
+		// Heaviest are the rules with the longest evaluation times, the candidates to move out.
+		Heaviest []Rule
+	}
+)
+
+type PromRuleGroupsPressure struct {
+	cfg *GroupPressureConfig
+}
+
+func NewPromRuleGroupsPressure(cfg *GroupPressureConfig) *PromRuleGroupsPressure {
+	return &PromRuleGroupsPressure{cfg: cfg}
+}
+
+// List returns the groups whose evaluation approaches their interval or lags, the most pressured ones first.
+func (pgp *PromRuleGroupsPressure) List(ctx context.Context) (*GroupPressureResult, error) {
+	rules, silentErrs, err := pgp.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		groups = make(map[string][]Rule)
+		order  []string
+		latest time.Time
+	)
+	for _, rule := range rules {
+		// exports of older versions don't have group stats
+		if rule.GroupInterval <= 0 {
+			continue
+		}
+		if _, ok := groups[rule.Group]; !ok {
+			order = append(order, rule.Group)
+		}
+		groups[rule.Group] = append(groups[rule.Group], rule)
+		if rule.GroupLastEval.After(latest) {
+			latest = rule.GroupLastEval
+		}
+	}
+
+	var res []GroupPressure
+	for _, name := range order {
+		if gp, ok := pgp.pressureOf(name, groups[name], latest); ok {
+			res = append(res, gp)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Ratio > res[j].Ratio
+	})
+	return &GroupPressureResult{
+		Groups:    res[:min(pgp.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+func (pgp *PromRuleGroupsPressure) pressureOf(name string, rules []Rule, latest time.Time) (GroupPressure, bool) {
+	first := rules[0]
+	gp := GroupPressure{
+		Group:    name,
+		Interval: secondsToDuration(first.GroupInterval),
+		EvalTime: secondsToDuration(first.GroupEvalDuration),
+		LastEval: first.GroupLastEval,
+		Ratio:    first.GroupEvalDuration / first.GroupInterval,
+	}
+	switch {
+	case gp.Ratio >= 1:
+		gp.Issues = append(gp.Issues, GroupIssueSaturated)
+	case gp.Ratio >= pgp.cfg.Threshold:
+		gp.Issues = append(gp.Issues, GroupIssuePressured)
+	}
+	if !gp.LastEval.IsZero() {
+		gp.Lag = latest.Sub(gp.LastEval)
+		if gp.Lag > time.Duration(pgp.cfg.LagFactor*float64(gp.Interval)) {
+			gp.Issues = append(gp.Issues, GroupIssueLagging)
+		}
+	}
+	if len(gp.Issues) == 0 {
+		return gp, false
+	}
+
+	if pgp.cfg.Threshold > 0 {
+		gp.SuggestedSplits = max(int(math.Ceil(gp.Ratio/pgp.cfg.Threshold)), 1)
+	}
+	heaviest := append([]Rule(nil), rules...)
+	sort.SliceStable(heaviest, func(i, j int) bool {
+		return heaviest[i].EvalDuration > heaviest[j].EvalDuration
+	})
+	const maxHeaviest = 3
+	gp.Heaviest = heaviest[:min(maxHeaviest, len(heaviest))]
+	return gp, true
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
+)
+
+type ProfileConfig struct {
+	*Source
+	VarsFile string
+	Addr     string
+	// Window is the time range the targets are queried over, e.g. 6h.
+	Window string
+	// Step is the resolution of the range queries, it's increased when the window would exceed the points limit.
+	Step        time.Duration
+	Concurrency int
+	Limit       uint64
+}
+
+type (
+	ProfileResult struct {
+		// Boards & Panels are ranked by cost, the most expensive first.
+		Boards    []BoardProfile
+		Panels    []PanelProfile
+		ParseErrs []error
+	}
+	BoardProfile struct {
+		Board *Board
+		QueryCost
+		Panels int
+	}
+	PanelProfile struct {
+		Board *Board
+		Panel *Panel
+		QueryCost
+	}
+	// QueryCost sums up the cost of the range queries of one or more targets.
+	QueryCost struct {
+		Queries  int
+		WallTime time.Duration
+		EvalTime time.Duration
+		Samples  int64
+		Series   int
+	}
+)
+
+type DashboardsProfiler struct {
+	cfg  *ProfileConfig
+	prom *promClient
+}
+
+func NewDashboardsProfiler(cfg *ProfileConfig) *DashboardsProfiler {
+	return &DashboardsProfiler{
+		cfg:  cfg,
+		prom: mustNewPromClient(cfg.Addr),
+	}
+}
+
+// Profile runs the targets of every panel with their variables replaced as range queries over the window,
+// collecting the samples processed as reported by prometheus, the series returned & the wall time.
+func (dp *DashboardsProfiler) Profile(ctx context.Context) (*ProfileResult, error) {
+	window, err := model.ParseDuration(dp.cfg.Window)
+	if err != nil {
+		return nil, fmt.Errorf("parse window: %w", err)
+	}
+	boards, silentErrs, err := dp.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := readVariablesFile(dp.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now()
+	r := promapiv1.Range{
+		Start: end.Add(-time.Duration(window)),
+		End:   end,
+		Step:  max(dp.cfg.Step, time.Duration(window)/maxQueryPoints),
+	}
+
+	var (
+		mu       sync.Mutex
+		profiles []*PanelProfile
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(dp.cfg.Concurrency, 1))
+	for _, board := range boards {
+		// $__range is what the window spans in grafana, $__interval is derived from the step of the queries
+		step := model.Duration(r.Step).String()
+		bvars := mergeVariables(Variables{"__range": dp.cfg.Window, "__interval": step, "interval": step}, board.Variables(), vars)
+		for _, panel := range board.AllPanels() {
+			pp := &PanelProfile{
+				Board: &Board{UID: board.UID, Title: board.Title},
+				Panel: &Panel{ID: panel.ID, Title: panel.Title},
+			}
+			var queried bool
+			for _, target := range panel.Targets {
+				// hidden queries aren't run
+				if target.Expr == "" || target.Hide {
+					continue
+				}
+				queried = true
+				eg.Go(func() error {
+					query := replaceVariables(target.Expr, bvars)
+					start := time.Now()
+					stats, err := dp.prom.queryRangeStats(egctx, query, r)
+					wall := time.Since(start)
+					mu.Lock()
+					defer mu.Unlock()
+					if err != nil {
+						silentErrs = append(silentErrs, fmt.Errorf("query range %s of %q: %w", query, board.UID, err))
+						return nil
+					}
+					pp.add(QueryCost{
+						Queries:  1,
+						WallTime: wall,
+						EvalTime: stats.EvalTime,
+						Samples:  stats.Samples,
+						Series:   stats.Series,
+					})
+					return nil
+				})
+			}
+			if queried {
+				profiles = append(profiles, pp)
+			}
+		}
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, fmt.Errorf("wait eg: %w", err)
+	}
+	panels := make([]PanelProfile, 0, len(profiles))
+	for _, pp := range profiles {
+		// panels whose queries all failed are reported as errors only
+		if pp.Queries > 0 {
+			panels = append(panels, *pp)
+		}
+	}
+
+	var (
+		res     []BoardProfile
+		byBoard = make(map[string]int)
+	)
+	for _, pp := range panels {
+		i, ok := byBoard[pp.Board.UID]
+		if !ok {
+			i = len(res)
+			byBoard[pp.Board.UID] = i
+			res = append(res, BoardProfile{Board: pp.Board})
+		}
+		res[i].add(pp.QueryCost)
+		res[i].Panels++
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].costlier(res[j].QueryCost) })
+	sort.SliceStable(panels, func(i, j int) bool { return panels[i].costlier(panels[j].QueryCost) })
+	return &ProfileResult{
+		Boards:    res[:min(dp.cfg.Limit, uint64(len(res)))],
+		Panels:    panels[:min(dp.cfg.Limit, uint64(len(panels)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+func (qc *QueryCost) add(o QueryCost) {
+	qc.Queries += o.Queries
+	qc.WallTime += o.WallTime
+	qc.EvalTime += o.EvalTime
+	qc.Samples += o.Samples
+	qc.Series += o.Series
+}
+
+// costlier ranks by samples processed, which is what loads prometheus, then by wall time.
+func (qc *QueryCost) costlier(o QueryCost) bool {
+	if qc.Samples != o.Samples {
+		return qc.Samples > o.Samples
+	}
+	return qc.WallTime > o.WallTime
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDashboardsProfilerInterval(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		queries = append(queries, r.Form.Get("query"))
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "dashboards.csv")
+	boards := []*Board{{UID: "abc", Title: "API", Panels: []*Panel{
+		{ID: 1, Title: "Requests", Targets: []Target{{Expr: "rate(http_requests_total[$__interval]) / $__range_s"}}},
+	}}}
+	if err := writeAllBoardsCSV(context.Background(), file, boards); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewDashboardsProfiler(&ProfileConfig{
+		Source: &Source{DashboardsFile: file},
+		Addr:   srv.URL,
+		Window: "1h",
+		Step:   30 * time.Second,
+	}).Profile(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// grafana derives $__interval from the step of the queries
+	want := "rate(http_requests_total[30s]) / 30"
+	if len(queries) != 1 || queries[0] != want {
+		t.Errorf("got queries %q, want %q", queries, want)
+	}
+}
@@ -1,33 +1,54 @@
 package internal
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"regexp"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/api"
 	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
-	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/promql/parser"
 )
 
 var (
 	validMetricNameExpr          = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
-	variableRangeQueryRangeRegex = regexp.MustCompile(`\[\$?\w+?]`)
-	variableSubqueryRangeRegex   = regexp.MustCompile(`\[\$?\w+:\$?\w+?]`)
-	variableReplacer             = strings.NewReplacer(
-		"$__interval", "5m",
-		"$interval", "5m",
-		"$resolution", "5s",
-		"$__rate_interval", "15s",
-		"$rate_interval", "15s",
-		"$__range", "1d",
-		"${__range_s:glob}", "30",
-		"${__range_s}", "30",
-	)
+	variableRangeQueryRangeRegex = regexp.MustCompile(`\[\$\w+]`)
+	variableSubqueryRangeRegex   = regexp.MustCompile(`\[(\$\w+:\$?\w*|\w+:\$\w+)]`)
 )
 
+// ExprError reports a query that can't be parsed even after its variables are replaced.
+type ExprError struct {
+	Expr string
+	Err  error
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("%q: %v", e.Expr, e.Err)
+}
+
+func (e *ExprError) Unwrap() error {
+	return e.Err
+}
+
+// CountExprErrs counts the errors caused by unparsable queries.
+func CountExprErrs(errs []error) int {
+	var c int
+	for _, err := range errs {
+		var ee *ExprError
+		if errors.As(err, &ee) {
+			c++
+		}
+	}
+	return c
+}
+
 func mustNewPromAPIV1(addr string) promapiv1.API {
 	v1api, err := newPromAPIV1(addr)
 	if err != nil {
@@ -36,6 +57,89 @@ func mustNewPromAPIV1(addr string) promapiv1.API {
 	return v1api
 }
 
+// promClient complements promapiv1.API with raw calls for the fields it doesn't decode.
+type promClient struct {
+	promapiv1.API
+	cl api.Client
+}
+
+func mustNewPromClient(addr string) *promClient {
+	cl, err := api.NewClient(api.Config{
+		Address: addr,
+	})
+	if err != nil {
+		panic(fmt.Errorf("new prom client: %w", err))
+	}
+	return &promClient{
+		API: promapiv1.NewAPI(cl),
+		cl:  cl,
+	}
+}
+
+// get calls the prometheus http api & decodes the data of the response into v.
+func (pc *promClient) get(ctx context.Context, path string, args url.Values, v any) error {
+	u := pc.cl.URL(path, nil)
+	u.RawQuery = args.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	resp, body, err := pc.cl.Do(ctx, req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	var res struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+		Error  string          `json:"error"`
+	}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("unmarshal response of %s, status: %d: %w", path, resp.StatusCode, err)
+	}
+	if res.Status != "success" {
+		return fmt.Errorf("%s failed, status: %d: %s", path, resp.StatusCode, res.Error)
+	}
+	if err = json.Unmarshal(res.Data, v); err != nil {
+		return fmt.Errorf("unmarshal data of %s: %w", path, err)
+	}
+	return nil
+}
+
+// ruleGroup is a rule group along with its evaluation stats, which promapiv1.RuleGroup lacks.
+type ruleGroup struct {
+	promapiv1.RuleGroup
+	EvaluationTime float64
+	LastEvaluation time.Time
+}
+
+func (pc *promClient) ruleGroups(ctx context.Context) ([]ruleGroup, error) {
+	var data struct {
+		Groups []json.RawMessage `json:"groups"`
+	}
+	if err := pc.get(ctx, "/api/v1/rules", nil, &data); err != nil {
+		return nil, err
+	}
+	groups := make([]ruleGroup, 0, len(data.Groups))
+	for _, raw := range data.Groups {
+		var (
+			g     ruleGroup
+			stats struct {
+				EvaluationTime float64   `json:"evaluationTime"`
+				LastEvaluation time.Time `json:"lastEvaluation"`
+			}
+		)
+		if err := json.Unmarshal(raw, &g.RuleGroup); err != nil {
+			return nil, fmt.Errorf("unmarshal rule group: %w", err)
+		}
+		if err := json.Unmarshal(raw, &stats); err != nil {
+			return nil, fmt.Errorf("unmarshal rule group stats: %w", err)
+		}
+		g.EvaluationTime, g.LastEvaluation = stats.EvaluationTime, stats.LastEvaluation
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
 func newPromAPIV1(addr string) (promapiv1.API, error) {
 	cl, err := api.NewClient(api.Config{
 		Address: addr,
@@ -46,42 +150,129 @@ func newPromAPIV1(addr string) (promapiv1.API, error) {
 	return promapiv1.NewAPI(cl), nil
 }
 
-func parsePromQuery(query string) (MetricNames, error) {
-	expr, err := parser.ParseExpr(replaceVariables(query))
+// promQuery holds the metrics referenced by a PromQL expression.
+type promQuery struct {
+	names MetricNames
+	// selectors are all vector selectors of the query.
+	selectors []*parser.VectorSelector
+	// matchers are __name__ matchers of selectors that don't name their metric
+	// exactly, e.g. {__name__=~"node_cpu.*"}. They need to be resolved against known metrics.
+	matchers [][]*labels.Matcher
+}
+
+// parseExpr parses the query after its variables are replaced.
+func parseExpr(query string, vars Variables) (parser.Expr, error) {
+	expr, err := parser.ParseExpr(replaceVariables(query, vars))
+	if err != nil {
+		return nil, &ExprError{Expr: query, Err: err}
+	}
+	return expr, nil
+}
+
+func parsePromQuery(query string, vars Variables) (*promQuery, error) {
+	expr, err := parseExpr(query, vars)
 	if err != nil {
 		return nil, err
 	}
 
-	var res MetricNames
+	var res promQuery
 	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
-		if n, ok := node.(*parser.VectorSelector); ok {
-			if n.Name != "" {
-				res = append(res, MetricName(n.Name))
-				return nil
+		n, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		res.selectors = append(res.selectors, n)
+		if n.Name != "" {
+			res.names = append(res.names, MetricName(n.Name))
+			return nil
+		}
+		var nameMatchers []*labels.Matcher
+		for _, m := range n.LabelMatchers {
+			if m.Name != labels.MetricName {
+				continue
 			}
-			for _, m := range n.LabelMatchers {
-				if m.Name == labels.MetricName && validMetricNameExpr.MatchString(m.Value) {
-					res = append(res, MetricName(n.Name))
-					return nil
-				}
+			if m.Type == labels.MatchEqual && validMetricNameExpr.MatchString(m.Value) {
+				res.names = append(res.names, MetricName(m.Value))
+				return nil
 			}
+			nameMatchers = append(nameMatchers, m)
+		}
+		if len(nameMatchers) > 0 {
+			res.matchers = append(res.matchers, nameMatchers)
 		}
 		return nil
 	})
-	return res, nil
+	return &res, nil
+}
+
+// resolve returns the metric names of the query, expanding name matchers to the matching metrics.
+func (pq *promQuery) resolve(metrics Metrics) MetricNames {
+	if len(pq.matchers) == 0 {
+		return pq.names
+	}
+	res := append(MetricNames{}, pq.names...)
+	for _, ms := range pq.matchers {
+		for m := range metrics {
+			if matchesAll(ms, string(m)) {
+				res = append(res, m)
+			}
+		}
+	}
+	return res
+}
+
+func matchesAll(ms []*labels.Matcher, s string) bool {
+	for _, m := range ms {
+		if !m.Matches(s) {
+			return false
+		}
+	}
+	return true
 }
 
-func replaceVariables(query string) string {
-	query = variableReplacer.Replace(query)
+// replaceVariables substitutes the given & built-in variables,
+// leftover variables in ranges are replaced with sane defaults.
+func replaceVariables(query string, vars Variables) string {
+	query = vars.replace(query)
 	query = variableRangeQueryRangeRegex.ReplaceAllLiteralString(query, `[5m]`)
 	query = variableSubqueryRangeRegex.ReplaceAllLiteralString(query, `[5m:1m]`)
 	return query
 }
 
-func humanizeLabelSet(labels model.LabelSet) string {
-	arr := make([]string, 0, len(labels))
-	for name, val := range labels {
-		arr = append(arr, fmt.Sprintf("%s=%s", name, val))
+// queryStats are the query stats prometheus returns for stats=all along with the result size.
+type queryStats struct {
+	Series  int
+	Samples int64
+	// EvalTime is the evaluation time reported by prometheus.
+	EvalTime time.Duration
+}
+
+// queryRangeStats runs a range query with stats=all, which promapiv1.API doesn't decode.
+func (pc *promClient) queryRangeStats(ctx context.Context, query string, r promapiv1.Range) (*queryStats, error) {
+	args := url.Values{
+		"query": {query},
+		"start": {strconv.FormatInt(r.Start.Unix(), 10)},
+		"end":   {strconv.FormatInt(r.End.Unix(), 10)},
+		"step":  {strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64)},
+		"stats": {"all"},
+	}
+	var data struct {
+		Result []json.RawMessage `json:"result"`
+		Stats  struct {
+			Timings struct {
+				EvalTotalTime float64 `json:"evalTotalTime"`
+			} `json:"timings"`
+			Samples struct {
+				TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+			} `json:"samples"`
+		} `json:"stats"`
+	}
+	if err := pc.get(ctx, "/api/v1/query_range", args, &data); err != nil {
+		return nil, err
 	}
-	return strings.Join(arr, ",")
+	return &queryStats{
+		Series:   len(data.Result),
+		Samples:  data.Stats.Samples.TotalQueryableSamples,
+		EvalTime: time.Duration(data.Stats.Timings.EvalTotalTime * float64(time.Second)),
+	}, nil
 }
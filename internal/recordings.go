@@ -0,0 +1,364 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"gopkg.in/yaml.v3"
+)
+
+type SuggestRecordingsConfig struct {
+	*Source
+	VarsFile string
+	// Output is the file the recording rules are written into.
+	Output string
+	// Group is the name of the rule group the recording rules are written into.
+	Group string
+	// MinOccurrences is the number of targets a subexpression has to be used in to be suggested.
+	MinOccurrences uint64
+	Limit          uint64
+}
+
+type (
+	SuggestRecordingsResult struct {
+		Suggestions []RecordingSuggestion
+		ParseErrs   []error
+	}
+	// RecordingSuggestion is a repeated dashboard subexpression worth a recording rule.
+	RecordingSuggestion struct {
+		Record string
+		Expr   string
+		// Occurrences is the number of targets using the subexpression.
+		Occurrences int
+		// Cost is the estimated number of samples an evaluation reads.
+		Cost  float64
+		Score float64
+		// Dashboards hold the panels that would switch to the recording rule.
+		Dashboards []*Board
+	}
+)
+
+// filterVarValue replaces the values of dashboard variables used as label filters,
+// the matchers holding it are dropped from subexpressions as they vary per view.
+const filterVarValue = "owl_dashboard_filter"
+
+type DashboardsRecordingSuggester struct {
+	cfg *SuggestRecordingsConfig
+}
+
+func NewDashboardsRecordingSuggester(cfg *SuggestRecordingsConfig) *DashboardsRecordingSuggester {
+	return &DashboardsRecordingSuggester{cfg: cfg}
+}
+
+// Suggest ranks the subexpressions repeated across dashboard targets by occurrences times estimated cost
+// & writes recording rules of the top ones into the output file.
+func (rs *DashboardsRecordingSuggester) Suggest(ctx context.Context) (*SuggestRecordingsResult, error) {
+	metrics, silentErrs, err := rs.cfg.readMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	boards, se, err := rs.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(rs.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	type usage struct {
+		expr parser.Expr
+		// nested are the keys of the subexpressions used within this one.
+		nested      map[string]struct{}
+		occurrences int
+		boards      []*Board
+	}
+	usages := make(map[string]*usage)
+	for _, board := range boards {
+		bvars := mergeVariables(board.Variables(), vars)
+		affected := make(map[string]*Board)
+		for _, panel := range board.AllPanels() {
+			for _, target := range panel.Targets {
+				if target.Expr == "" {
+					continue
+				}
+				subs, err := dashboardSubexprs(target.Expr, bvars)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse expr of %q: %w", board.UID, err))
+					continue
+				}
+				for key, sub := range subs {
+					u, ok := usages[key]
+					if !ok {
+						u = &usage{expr: sub.expr, nested: make(map[string]struct{})}
+						usages[key] = u
+					}
+					maps.Copy(u.nested, sub.nested)
+					u.occurrences++
+					b, ok := affected[key]
+					if !ok {
+						b = &Board{UID: board.UID, Title: board.Title}
+						affected[key] = b
+						u.boards = append(u.boards, b)
+					}
+					if !slices.ContainsFunc(b.Panels, func(p *Panel) bool { return p.ID == panel.ID }) {
+						b.Panels = append(b.Panels, &Panel{ID: panel.ID, Title: panel.Title})
+					}
+				}
+			}
+		}
+	}
+
+	var res []RecordingSuggestion
+	for key, u := range usages {
+		if uint64(u.occurrences) < max(rs.cfg.MinOccurrences, 1) {
+			continue
+		}
+		// a subexpression only used as part of a larger repeated one is covered by the latter
+		covered := false
+		for other, ou := range usages {
+			_, nested := ou.nested[key]
+			if other != key && ou.occurrences >= u.occurrences && nested &&
+				uint64(ou.occurrences) >= max(rs.cfg.MinOccurrences, 1) {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			continue
+		}
+		cost := exprCost(u.expr, metrics)
+		res = append(res, RecordingSuggestion{
+			Expr:        key,
+			Occurrences: u.occurrences,
+			Cost:        cost,
+			Score:       float64(u.occurrences) * cost,
+			Dashboards:  u.boards,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].Expr < res[j].Expr
+	})
+	res = res[:min(rs.cfg.Limit, uint64(len(res)))]
+
+	taken := make(map[string]struct{})
+	for i := range res {
+		name, err := suggestRecordingName(Rule{Query: res[i].Expr})
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("suggest name of %q: %w", res[i].Expr, err))
+			name = "cluster:dashboard:recording"
+		}
+		res[i].Record = name
+		for n := 2; ; n++ {
+			if _, ok := taken[res[i].Record]; !ok {
+				break
+			}
+			res[i].Record = fmt.Sprintf("%s_%d", name, n)
+		}
+		taken[res[i].Record] = struct{}{}
+	}
+
+	if err = writeRecordingRules(rs.cfg.Output, rs.cfg.Group, res); err != nil {
+		return nil, err
+	}
+	return &SuggestRecordingsResult{
+		Suggestions: res,
+		ParseErrs:   silentErrs,
+	}, nil
+}
+
+// subexpr is a subexpression of a dashboard query along with the keys of the subexpressions nested in it.
+type subexpr struct {
+	expr   parser.Expr
+	nested map[string]struct{}
+}
+
+// dashboardSubexprs returns the normalized functions & aggregations of the query that read ranges by their normalized form,
+// matchers filtering by dashboard variables are dropped & their labels kept by the aggregations.
+func dashboardSubexprs(query string, vars Variables) (map[string]*subexpr, error) {
+	expr, err := parseExpr(query, filterVariables(vars))
+	if err != nil {
+		// variables used beyond label filters, e.g. as function names, can't be told apart
+		if expr, err = parseExpr(query, vars); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		res  = make(map[string]*subexpr)
+		keys = make(map[parser.Node]string)
+	)
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch node.(type) {
+		case *parser.AggregateExpr, *parser.Call:
+		default:
+			return nil
+		}
+		if !readsRange(node) {
+			return nil
+		}
+		// the subexpression is modified, so it's parsed anew
+		sub, err := parser.ParseExpr(node.String())
+		if err != nil {
+			return nil
+		}
+		dropFilters(sub)
+		key := normalizeExpr(sub)
+		keys[node] = key
+		if _, ok := res[key]; !ok {
+			res[key] = &subexpr{expr: sub, nested: make(map[string]struct{})}
+		}
+		return nil
+	})
+	// nesting is told by the syntax tree, a key being part of another one's text doesn't make it nested
+	for node, key := range keys {
+		parser.Inspect(node, func(child parser.Node, _ []parser.Node) error {
+			if ck, ok := keys[child]; ok && child != node && ck != key {
+				res[key].nested[ck] = struct{}{}
+			}
+			return nil
+		})
+	}
+	return res, nil
+}
+
+// filterVariables maps the variables to values recognizable after parsing,
+// interval variables are mapped to a range fit for recording rules.
+func filterVariables(vars Variables) Variables {
+	res := Variables{
+		"__interval":      "5m",
+		"interval":        "5m",
+		"__rate_interval": "5m",
+		"rate_interval":   "5m",
+		"__range":         "5m",
+	}
+	for name, val := range vars {
+		if _, err := model.ParseDuration(val); err == nil {
+			res[name] = "5m"
+			continue
+		}
+		res[name] = filterVarValue
+	}
+	return res
+}
+
+func readsRange(node parser.Node) bool {
+	var found bool
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		if _, ok := n.(*parser.MatrixSelector); ok {
+			found = true
+		}
+		return nil
+	})
+	return found
+}
+
+// dropFilters removes the matchers of dashboard variables & keeps their labels in aggregations.
+func dropFilters(expr parser.Expr) {
+	filtered := stripFilters(expr)
+	if len(filtered) == 0 {
+		return
+	}
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		agg, ok := node.(*parser.AggregateExpr)
+		if !ok {
+			return nil
+		}
+		for _, l := range distinct(filtered) {
+			switch {
+			case agg.Without:
+				agg.Grouping = slices.DeleteFunc(agg.Grouping, func(g string) bool { return g == l })
+			case !slices.Contains(agg.Grouping, l):
+				agg.Grouping = append(agg.Grouping, l)
+			}
+		}
+		return nil
+	})
+}
+
+// stripFilters removes the matchers of dashboard variables & returns their label names.
+func stripFilters(expr parser.Expr) []string {
+	var filtered []string
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		vs.LabelMatchers = slices.DeleteFunc(vs.LabelMatchers, func(m *labels.Matcher) bool {
+			// references of variables without a value are kept as written
+			if strings.Contains(m.Value, filterVarValue) || strings.Contains(m.Value, "$") {
+				filtered = append(filtered, m.Name)
+				return true
+			}
+			return false
+		})
+		return nil
+	})
+	return distinct(filtered)
+}
+
+// exprCost estimates the samples an evaluation reads: the series of each selector,
+// as far as they are known, times the minutes of its range.
+func exprCost(expr parser.Expr, metrics Metrics) float64 {
+	var cost float64
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		series := 1.0
+		if m, ok := metrics[MetricName(selectorName(vs))]; ok && m.Series > 0 {
+			series = float64(m.Series)
+		}
+		minutes := 1.0
+		if len(path) > 0 {
+			if ms, ok := path[len(path)-1].(*parser.MatrixSelector); ok {
+				minutes = math.Max(1, ms.Range.Minutes())
+			}
+		}
+		cost += series * minutes
+		return nil
+	})
+	return cost
+}
+
+type (
+	ruleFile struct {
+		Groups []ruleFileGroup `yaml:"groups"`
+	}
+	ruleFileGroup struct {
+		Name  string         `yaml:"name"`
+		Rules []ruleFileRule `yaml:"rules"`
+	}
+	ruleFileRule struct {
+		Record string `yaml:"record"`
+		Expr   string `yaml:"expr"`
+	}
+)
+
+func writeRecordingRules(file, group string, suggestions []RecordingSuggestion) error {
+	rf := ruleFile{Groups: []ruleFileGroup{{Name: group}}}
+	for _, s := range suggestions {
+		rf.Groups[0].Rules = append(rf.Groups[0].Rules, ruleFileRule{Record: s.Record, Expr: s.Expr})
+	}
+	bs, err := yaml.Marshal(rf)
+	if err != nil {
+		return fmt.Errorf("marshal recording rules: %w", err)
+	}
+	if err = os.WriteFile(file, bs, 0o644); err != nil {
+		return fmt.Errorf("write recording rules: %w", err)
+	}
+	return nil
+}
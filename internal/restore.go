@@ -0,0 +1,319 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-openapi/runtime"
+	goapi "github.com/grafana/grafana-openapi-client-go/client"
+	"github.com/grafana/grafana-openapi-client-go/client/dashboards"
+	"github.com/grafana/grafana-openapi-client-go/client/folders"
+	"github.com/grafana/grafana-openapi-client-go/models"
+	"golang.org/x/sync/errgroup"
+)
+
+type DashboardsRestoreConfig struct {
+	Addr     string
+	SvcToken string
+	// Dir is the backup directory the dashboards are read from.
+	Dir string
+	// OrgID is the organization the dashboards are restored into, the one of the token when zero.
+	OrgID int64
+	// Policy decides about dashboards that exist with changes, either RestoreSkip or RestoreOverwrite.
+	Policy string
+	// DryRun only reports what a restore would change.
+	DryRun      bool
+	Concurrency int
+}
+
+// Policies for dashboards that exist already.
+const (
+	RestoreSkip      = "skip"
+	RestoreOverwrite = "overwrite"
+)
+
+// Actions taken per dashboard.
+const (
+	RestoreActionCreate    = "create"
+	RestoreActionOverwrite = "overwrite"
+	RestoreActionSkip      = "skip"
+	RestoreActionUnchanged = "unchanged"
+)
+
+type (
+	RestoreResult struct {
+		Total int
+		// Folders are the folders missing in grafana, created unless it's a dry run.
+		Folders    []RestoredFolder
+		Dashboards []RestoredDashboard
+		ParseErrs  []error
+	}
+	RestoredFolder struct {
+		UID       string
+		Title     string
+		ParentUID string
+	}
+	RestoredDashboard struct {
+		UID    string
+		Title  string
+		Folder string
+		Action string
+		// Changes are the json paths of the model that differ from the dashboard in grafana.
+		Changes []string
+	}
+)
+
+// restoreMessage is the version message of restored dashboards.
+const restoreMessage = "restored by owl"
+
+type DashboardsRestorer struct {
+	cfg     *DashboardsRestoreConfig
+	grafana *goapi.GrafanaHTTPAPI
+}
+
+func NewDashboardsRestorer(cfg *DashboardsRestoreConfig) *DashboardsRestorer {
+	return &DashboardsRestorer{
+		cfg: cfg,
+		grafana: newGrafanaOAPI(&GrafanaConfig{
+			URL:    cfg.Addr,
+			Scheme: "https",
+			APIKey: cfg.SvcToken,
+			OrgID:  cfg.OrgID,
+		}),
+	}
+}
+
+// Restore pushes the dashboards of a backup directory to grafana keeping their uids,
+// after creating the folders they're in. Dashboards that exist with changes are skipped or overwritten by the policy.
+func (dr *DashboardsRestorer) Restore(ctx context.Context) (*RestoreResult, error) {
+	if dr.cfg.Policy != RestoreSkip && dr.cfg.Policy != RestoreOverwrite {
+		return nil, fmt.Errorf("unknown policy %q, expected %s or %s", dr.cfg.Policy, RestoreSkip, RestoreOverwrite)
+	}
+	var files []*backupFile
+	silentErrs, err := walkBackups(dr.cfg.Dir, func(_ string, f *backupFile) {
+		files = append(files, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	created, err := dr.restoreFolders(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu  sync.Mutex
+		res = &RestoreResult{Total: len(files), Folders: created}
+	)
+	eg, egctx := errgroup.WithContext(ctx)
+	eg.SetLimit(max(dr.cfg.Concurrency, 1))
+	for _, f := range files {
+		eg.Go(func() error {
+			rd, err := dr.restore(egctx, f)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				silentErrs = append(silentErrs, err)
+				return nil
+			}
+			res.Dashboards = append(res.Dashboards, *rd)
+			return nil
+		})
+	}
+	if err = eg.Wait(); err != nil {
+		return nil, fmt.Errorf("wait eg: %w", err)
+	}
+	sort.Slice(res.Dashboards, func(i, j int) bool {
+		if res.Dashboards[i].Folder != res.Dashboards[j].Folder {
+			return res.Dashboards[i].Folder < res.Dashboards[j].Folder
+		}
+		return res.Dashboards[i].UID < res.Dashboards[j].UID
+	})
+	res.ParseErrs = silentErrs
+	return res, nil
+}
+
+// restoreFolders creates the folders of the backup missing in grafana with their uids & titles.
+// Nested folders are created in their parents, which are created first, as listed in the folders file of the backup.
+func (dr *DashboardsRestorer) restoreFolders(ctx context.Context, files []*backupFile) ([]RestoredFolder, error) {
+	listed, err := readBackupFolders(dr.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]backupFolder)
+	for _, f := range files {
+		if f.Meta == nil || f.Meta.FolderUID == "" {
+			continue
+		}
+		uid := f.Meta.FolderUID
+		if _, ok := listed[uid]; !ok {
+			wanted[uid] = backupFolder{UID: uid, Title: f.Meta.FolderTitle}
+			continue
+		}
+		for lf, ok := listed[uid]; ok; lf, ok = listed[lf.ParentUID] {
+			if _, seen := wanted[lf.UID]; seen {
+				break
+			}
+			wanted[lf.UID] = lf
+		}
+	}
+	depth := func(f backupFolder) int {
+		var d int
+		for ; f.ParentUID != "" && d < len(wanted); d++ {
+			f = wanted[f.ParentUID]
+		}
+		return d
+	}
+	withCtx := func(op *runtime.ClientOperation) {
+		op.Context = ctx
+	}
+
+	var res []RestoredFolder
+	ordered := slices.SortedFunc(maps.Values(wanted), func(a, b backupFolder) int {
+		if c := depth(a) - depth(b); c != 0 {
+			return c
+		}
+		return strings.Compare(a.UID, b.UID)
+	})
+	for _, bf := range ordered {
+		uid := bf.UID
+		_, err := dr.grafana.Folders.GetFolderByUID(uid, withCtx)
+		var notFound *folders.GetFolderByUIDNotFound
+		if err == nil {
+			continue
+		}
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("get folder %q: %w", uid, err)
+		}
+		if !dr.cfg.DryRun {
+			cmd := &models.CreateFolderCommand{UID: uid, Title: bf.Title, ParentUID: bf.ParentUID}
+			if _, err = dr.grafana.Folders.CreateFolder(cmd, withCtx); err != nil {
+				return nil, fmt.Errorf("create folder %q: %w", uid, err)
+			}
+		}
+		res = append(res, RestoredFolder{UID: uid, Title: bf.Title, ParentUID: bf.ParentUID})
+	}
+	return res, nil
+}
+
+func (dr *DashboardsRestorer) restore(ctx context.Context, f *backupFile) (*RestoredDashboard, error) {
+	model, ok := f.Dashboard.(map[string]any)
+	if !ok {
+		return nil, errors.New("backup holds no dashboard model")
+	}
+	entry := backupEntryOf(f)
+	if entry.UID == "" {
+		return nil, fmt.Errorf("backup of %q has no uid", entry.Title)
+	}
+	var folderUID string
+	if f.Meta != nil {
+		folderUID = f.Meta.FolderUID
+	}
+	rd := &RestoredDashboard{UID: entry.UID, Title: entry.Title, Folder: entry.Folder}
+
+	current, err := getDashboardModelByUID(ctx, dr.grafana, entry.UID)
+	var notFound *dashboards.GetDashboardByUIDNotFound
+	switch {
+	case errors.As(err, &notFound):
+		rd.Action = RestoreActionCreate
+	case err != nil:
+		return nil, fmt.Errorf("restore %q: %w", entry.UID, err)
+	default:
+		rd.Changes = dashboardChanges(current, f)
+		switch {
+		case len(rd.Changes) == 0:
+			rd.Action = RestoreActionUnchanged
+		case dr.cfg.Policy == RestoreSkip:
+			rd.Action = RestoreActionSkip
+		default:
+			rd.Action = RestoreActionOverwrite
+		}
+	}
+	if dr.cfg.DryRun || rd.Action == RestoreActionUnchanged || rd.Action == RestoreActionSkip {
+		return rd, nil
+	}
+
+	// ids are given by every grafana on its own, the uid identifies the dashboard
+	model = maps.Clone(model)
+	delete(model, "id")
+	cmd := &models.SaveDashboardCommand{
+		Dashboard: model,
+		FolderUID: folderUID,
+		Overwrite: rd.Action == RestoreActionOverwrite,
+		Message:   restoreMessage,
+	}
+	_, err = dr.grafana.Dashboards.PostDashboard(cmd, func(op *runtime.ClientOperation) {
+		op.Context = ctx
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore %q: post dashboard: %w", entry.UID, err)
+	}
+	return rd, nil
+}
+
+// dashboardChanges returns the json paths of the backed up model that differ from the dashboard in grafana,
+// ignoring the id & version that grafana maintains. A folder change is reported as "folder".
+func dashboardChanges(current, backup *backupFile) []string {
+	var res []string
+	if folderUIDOf(current) != folderUIDOf(backup) {
+		res = append(res, "folder")
+	}
+	cur, _ := current.Dashboard.(map[string]any)
+	want, _ := backup.Dashboard.(map[string]any)
+	cur, want = maps.Clone(cur), maps.Clone(want)
+	for _, k := range []string{"id", "version"} {
+		delete(cur, k)
+		delete(want, k)
+	}
+	return append(res, jsonChanges("", cur, want)...)
+}
+
+func folderUIDOf(f *backupFile) string {
+	if f.Meta == nil {
+		return ""
+	}
+	return f.Meta.FolderUID
+}
+
+// jsonChanges returns the paths at which the decoded json values differ, descending into objects & arrays of equal length.
+func jsonChanges(path string, cur, want any) []string {
+	switch w := want.(type) {
+	case map[string]any:
+		c, ok := cur.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := maps.Clone(w)
+		maps.Copy(keys, c)
+		var res []string
+		for _, k := range slices.Sorted(maps.Keys(keys)) {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			res = append(res, jsonChanges(p, c[k], w[k])...)
+		}
+		return res
+	case []any:
+		c, ok := cur.([]any)
+		if !ok || len(c) != len(w) {
+			break
+		}
+		var res []string
+		for i := range w {
+			res = append(res, jsonChanges(fmt.Sprintf("%s[%d]", path, i), c[i], w[i])...)
+		}
+		return res
+	}
+	if reflect.DeepEqual(cur, want) {
+		return nil
+	}
+	return []string{path}
+}
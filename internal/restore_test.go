@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-openapi-client-go/models"
+)
+
+func TestJSONChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		cur, want any
+		changes   []string
+	}{
+		{
+			name:    "equal",
+			cur:     map[string]any{"title": "A", "tags": []any{"x"}},
+			want:    map[string]any{"tags": []any{"x"}, "title": "A"},
+			changes: nil,
+		},
+		{
+			name:    "nested value",
+			cur:     map[string]any{"time": map[string]any{"from": "now-6h", "to": "now"}},
+			want:    map[string]any{"time": map[string]any{"from": "now-1h", "to": "now"}},
+			changes: []string{"time.from"},
+		},
+		{
+			name:    "added & removed keys",
+			cur:     map[string]any{"a": 1.0, "b": 2.0},
+			want:    map[string]any{"b": 2.0, "c": 3.0},
+			changes: []string{"a", "c"},
+		},
+		{
+			name:    "array items",
+			cur:     map[string]any{"panels": []any{map[string]any{"id": 1.0}, map[string]any{"id": 2.0, "title": "CPU"}}},
+			want:    map[string]any{"panels": []any{map[string]any{"id": 1.0}, map[string]any{"id": 2.0, "title": "Memory"}}},
+			changes: []string{"panels[1].title"},
+		},
+		{
+			name:    "array length",
+			cur:     map[string]any{"panels": []any{1.0}},
+			want:    map[string]any{"panels": []any{1.0, 2.0}},
+			changes: []string{"panels"},
+		},
+		{
+			name:    "type",
+			cur:     map[string]any{"refresh": false},
+			want:    map[string]any{"refresh": "5m"},
+			changes: []string{"refresh"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonChanges("", tt.cur, tt.want); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("got %v, want %v", got, tt.changes)
+			}
+		})
+	}
+}
+
+func TestDashboardChanges(t *testing.T) {
+	current := &backupFile{
+		Dashboard: map[string]any{"id": 7.0, "uid": "a", "title": "A", "version": 12.0},
+		Meta:      &models.DashboardMeta{FolderUID: "ops", Version: 12},
+	}
+	tests := []struct {
+		name    string
+		backup  *backupFile
+		changes []string
+	}{
+		{
+			name: "ids & versions are grafana's",
+			backup: &backupFile{
+				Dashboard: map[string]any{"id": 1.0, "uid": "a", "title": "A", "version": 3.0},
+				Meta:      &models.DashboardMeta{FolderUID: "ops", Version: 3},
+			},
+		},
+		{
+			name: "moved & renamed",
+			backup: &backupFile{
+				Dashboard: map[string]any{"uid": "a", "title": "B"},
+				Meta:      &models.DashboardMeta{FolderUID: "infra"},
+			},
+			changes: []string{"folder", "title"},
+		},
+		{
+			name:    "general folder",
+			backup:  &backupFile{Dashboard: map[string]any{"uid": "a", "title": "A"}},
+			changes: []string{"folder"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dashboardChanges(current, tt.backup); !reflect.DeepEqual(got, tt.changes) {
+				t.Errorf("got %v, want %v", got, tt.changes)
+			}
+		})
+	}
+}
+
+func TestDashboardsRestorer(t *testing.T) {
+	ctx := context.Background()
+	source := newFakeGrafana()
+	source.addFolder("infra", "Infra", "")
+	source.addFolder("ops", "Ops", "infra")
+	source.addDashboard("ops", map[string]any{"uid": "a", "title": "A", "version": 1, "panels": []any{}})
+	source.addDashboard("", map[string]any{"uid": "b", "title": "B", "version": 2})
+	dir := t.TempDir()
+	if _, err := NewDashboardsBackuper(&DashboardsBackupConfig{Addr: source.start(t), Dir: dir}).Backup(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	target := newFakeGrafana()
+	addr := target.start(t)
+	restore := func(policy string, dryRun bool) *RestoreResult {
+		t.Helper()
+		res, err := NewDashboardsRestorer(&DashboardsRestoreConfig{
+			Addr: addr, Dir: dir, OrgID: 2, Policy: policy, DryRun: dryRun,
+		}).Restore(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.ParseErrs) > 0 {
+			t.Fatalf("restore errors: %v", res.ParseErrs)
+		}
+		return res
+	}
+	actions := func(res *RestoreResult) map[string]string {
+		got := make(map[string]string)
+		for _, rd := range res.Dashboards {
+			got[rd.UID] = rd.Action
+		}
+		return got
+	}
+
+	// a dry run only tells what would be created
+	res := restore(RestoreSkip, true)
+	if len(res.Folders) != 2 || len(target.folders) != 0 || len(target.dashboards) != 0 {
+		t.Fatalf("dry run restored %d folders & %d dashboards, want none", len(target.folders), len(target.dashboards))
+	}
+
+	// nested folders are created after their parents
+	res = restore(RestoreSkip, false)
+	wantFolders := []RestoredFolder{{UID: "infra", Title: "Infra"}, {UID: "ops", Title: "Ops", ParentUID: "infra"}}
+	if !reflect.DeepEqual(res.Folders, wantFolders) {
+		t.Errorf("got folders %+v, want %+v", res.Folders, wantFolders)
+	}
+	if target.parents["ops"] != "infra" || target.dashFolder["a"] != "ops" {
+		t.Errorf("got ops in %q & a in %q, want ops in infra & a in ops", target.parents["ops"], target.dashFolder["a"])
+	}
+	if got := actions(res); !reflect.DeepEqual(got, map[string]string{"a": RestoreActionCreate, "b": RestoreActionCreate}) {
+		t.Errorf("got actions %v, want both created", got)
+	}
+	if len(target.orgIDs) == 0 {
+		t.Error("got no org id, want 2")
+	}
+	for _, id := range target.orgIDs {
+		if id != "2" {
+			t.Fatalf("got org id %s, want 2", id)
+		}
+	}
+
+	res = restore(RestoreSkip, false)
+	if len(res.Folders) != 0 {
+		t.Errorf("got folders %+v, want none as they exist", res.Folders)
+	}
+	if got := actions(res); !reflect.DeepEqual(got, map[string]string{"a": RestoreActionUnchanged, "b": RestoreActionUnchanged}) {
+		t.Errorf("got actions %v, want both unchanged", got)
+	}
+
+	// dashboards edited since are skipped or overwritten by the policy
+	target.addDashboard("ops", map[string]any{"uid": "a", "title": "A edited", "version": 5.0, "panels": []any{}})
+	res = restore(RestoreSkip, false)
+	if got := actions(res); !reflect.DeepEqual(got, map[string]string{"a": RestoreActionSkip, "b": RestoreActionUnchanged}) {
+		t.Errorf("got actions %v, want a skipped", got)
+	}
+	for _, rd := range res.Dashboards {
+		if rd.UID == "a" && !reflect.DeepEqual(rd.Changes, []string{"title"}) {
+			t.Errorf("got changes %v of a, want title", rd.Changes)
+		}
+	}
+	if target.dashboards["a"]["title"] != "A edited" {
+		t.Errorf("got title %v, want the skipped dashboard untouched", target.dashboards["a"]["title"])
+	}
+
+	res = restore(RestoreOverwrite, false)
+	if got := actions(res); !reflect.DeepEqual(got, map[string]string{"a": RestoreActionOverwrite, "b": RestoreActionUnchanged}) {
+		t.Errorf("got actions %v, want a overwritten", got)
+	}
+	if target.dashboards["a"]["title"] != "A" {
+		t.Errorf("got title %v, want the backed up one", target.dashboards["a"]["title"])
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+type RecordingRewritesConfig struct {
+	*Source
+	VarsFile string
+}
+
+type (
+	RecordingRewritesResult struct {
+		Rewrites  []RecordingRewrite
+		ParseErrs []error
+	}
+	// RecordingRewrite is a dashboard target subexpression an existing recording rule already evaluates.
+	RecordingRewrite struct {
+		Board *Board
+		Panel *Panel
+		// Expr is the query of the target.
+		Expr string
+		// Subexpr is the normalized subexpression the recording rule replaces.
+		Subexpr string
+		Record  string
+		// Filters are the labels of dashboard variable filters to apply on the recording rule's output.
+		Filters []string
+	}
+)
+
+type DashboardsRecordingRewriter struct {
+	cfg *RecordingRewritesConfig
+}
+
+func NewDashboardsRecordingRewriter(cfg *RecordingRewritesConfig) *DashboardsRecordingRewriter {
+	return &DashboardsRecordingRewriter{cfg: cfg}
+}
+
+// List matches the normalized subexpressions of dashboard targets against the queries of recording rules,
+// the largest matching subexpression of a target is reported.
+func (rr *DashboardsRecordingRewriter) List(ctx context.Context) (*RecordingRewritesResult, error) {
+	rules, silentErrs, err := rr.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	boards, se, err := rr.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(rr.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := make(map[string]string)
+	for _, rule := range rules {
+		if rule.Type != "record" {
+			continue
+		}
+		norm, err := normalizeQuery(rule.Query, nil)
+		if err != nil {
+			silentErrs = append(silentErrs, fmt.Errorf("parse prom expr: %w", err))
+			continue
+		}
+		if _, ok := recorded[norm]; !ok {
+			recorded[norm] = rule.Name
+		}
+	}
+
+	var res []RecordingRewrite
+	for _, board := range boards {
+		bvars := mergeVariables(board.Variables(), vars)
+		for _, panel := range board.AllPanels() {
+			for _, target := range panel.Targets {
+				if target.Expr == "" {
+					continue
+				}
+				expr, err := parseExpr(target.Expr, filterVariables(bvars))
+				if err != nil {
+					if expr, err = parseExpr(target.Expr, bvars); err != nil {
+						silentErrs = append(silentErrs, fmt.Errorf("parse expr of %q: %w", board.UID, err))
+						continue
+					}
+				}
+				for _, m := range recordedSubexprs(expr, recorded) {
+					m.Board = &Board{UID: board.UID, Title: board.Title}
+					m.Panel = &Panel{ID: panel.ID, Title: panel.Title}
+					m.Expr = target.Expr
+					res = append(res, m)
+				}
+			}
+		}
+	}
+	return &RecordingRewritesResult{
+		Rewrites:  res,
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+// recordedSubexprs walks the expression top down & returns the outermost subexpressions
+// recorded by a rule, subexpressions of a match aren't visited.
+func recordedSubexprs(node parser.Node, recorded map[string]string) []RecordingRewrite {
+	switch node.(type) {
+	case *parser.AggregateExpr, *parser.Call, *parser.BinaryExpr:
+		// the subexpression is modified, so it's parsed anew
+		if sub, err := parser.ParseExpr(node.String()); err == nil {
+			filters := stripFilters(sub)
+			norm := normalizeExpr(sub)
+			if record, ok := recorded[norm]; ok && keepsLabels(sub, filters) {
+				return []RecordingRewrite{{Subexpr: norm, Record: record, Filters: filters}}
+			}
+		}
+	}
+	var res []RecordingRewrite
+	for _, child := range parser.Children(node) {
+		res = append(res, recordedSubexprs(child, recorded)...)
+	}
+	return res
+}
+
+// keepsLabels reports whether the labels survive every aggregation of the expression,
+// so filtering the output equals filtering the input.
+func keepsLabels(expr parser.Expr, names []string) bool {
+	keeps := true
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		agg, ok := node.(*parser.AggregateExpr)
+		if !ok {
+			return nil
+		}
+		for _, l := range names {
+			if agg.Without == slices.Contains(agg.Grouping, l) {
+				keeps = false
+			}
+		}
+		return nil
+	})
+	return keeps
+}
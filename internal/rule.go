@@ -3,12 +3,18 @@ package internal
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/grafana/grafana-openapi-client-go/models"
 	promapiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
 )
 
 type colRule uint8
@@ -21,17 +27,187 @@ const (
 	colRuleLabels
 	colRuleEvalTime
 	colRuleLastEval
+	colRuleHealth
+	colRuleLastError
+	colRuleState
+	colRuleActiveAlerts
+	colRuleGroupInterval
+	colRuleGroupEvalTime
+	colRuleGroupLastEval
+	colRuleFor
+	colRuleAnnotations
+	colRuleSource
+	colRuleFolder
+	colRuleRefID
+	colRuleDatasource
 	colRuleNum
 )
 
+var ruleHeaders = [colRuleNum]string{
+	"group", "type", "name", "query", "labels", "evalTime", "lastEval",
+	"health", "lastError", "state", "activeAlerts",
+	"groupInterval", "groupEvalTime", "groupLastEval", "for", "annotations",
+	"source", "folder", "refId", "datasource",
+}
+
+// Sources of rules, exports of older versions have prometheus rules only & leave it empty.
+const (
+	RuleSourcePrometheus = "prometheus"
+	RuleSourceGrafana    = "grafana"
+)
+
 type RuleName string
 
 type Rule struct {
-	Group, Type, Name, Query, Labels string
-	EvalDuration                     float64
+	Group        string         `json:"group"`
+	Type         string         `json:"type"`
+	Name         string         `json:"name"`
+	Query        string         `json:"query"`
+	Labels       model.LabelSet `json:"labels,omitempty"`
+	EvalDuration float64        `json:"evalTime"`
+	LastEval     time.Time      `json:"lastEval"`
+	Health       string         `json:"health,omitempty"`
+	LastError    string         `json:"lastError,omitempty"`
+	State        string         `json:"state,omitempty"`        // alerting rules only
+	ActiveAlerts int            `json:"activeAlerts,omitempty"` // alerting rules only
+	For          float64        `json:"for,omitempty"`          // alerting rules only, in seconds
+	Annotations  model.LabelSet `json:"annotations,omitempty"`  // alerting rules only
+	// GroupInterval, GroupEvalDuration & GroupLastEval are the evaluation stats of the rule's group.
+	GroupInterval     float64   `json:"groupInterval,omitempty"`
+	GroupEvalDuration float64   `json:"groupEvalTime,omitempty"`
+	GroupLastEval     time.Time `json:"groupLastEval"`
+	Source            string    `json:"source,omitempty"`
+	// Folder, RefID & Datasource are set for grafana managed rules only,
+	// which are exported once per prometheus query they hold, RefID telling the queries apart.
+	Folder     string `json:"folder,omitempty"`
+	RefID      string `json:"refId,omitempty"`
+	Datasource string `json:"datasource,omitempty"`
+}
+
+// IsGrafana reports whether the rule is a grafana managed one, evaluated by grafana rather than prometheus.
+func (r *Rule) IsGrafana() bool {
+	return r.Source == RuleSourceGrafana
+}
+
+func rulesFrom(groups []ruleGroup) []Rule {
+	var rules []Rule
+	for _, group := range groups {
+		from := len(rules)
+		for _, rule := range group.Rules {
+			switch r := rule.(type) {
+			case promapiv1.RecordingRule:
+				rules = append(rules, Rule{
+					Group:        group.Name,
+					Type:         "record",
+					Name:         r.Name,
+					Query:        r.Query,
+					Labels:       r.Labels,
+					EvalDuration: r.EvaluationTime,
+					LastEval:     r.LastEvaluation,
+					Health:       string(r.Health),
+					LastError:    r.LastError,
+				})
+			case promapiv1.AlertingRule:
+				rules = append(rules, Rule{
+					Group:        group.Name,
+					Type:         "alert",
+					Name:         r.Name,
+					Query:        r.Query,
+					Labels:       r.Labels,
+					EvalDuration: r.EvaluationTime,
+					LastEval:     r.LastEvaluation,
+					Health:       string(r.Health),
+					LastError:    r.LastError,
+					State:        r.State,
+					ActiveAlerts: len(r.Alerts),
+					For:          r.Duration,
+					Annotations:  r.Annotations,
+				})
+			default:
+			}
+		}
+		for i := from; i < len(rules); i++ {
+			rules[i].Source = RuleSourcePrometheus
+			rules[i].GroupInterval = group.Interval
+			rules[i].GroupEvalDuration = group.EvaluationTime
+			rules[i].GroupLastEval = group.LastEvaluation
+		}
+	}
+	return rules
+}
+
+// grafanaRulesFrom converts grafana managed rules into one rule per prometheus query, skipping rules without any.
+// Datasources of unknown type are assumed to be prometheus ones, expressions are never.
+func grafanaRulesFrom(grules models.ProvisionedAlertRules, dsTypes, folders map[string]string) []Rule {
+	var rules []Rule
+	for _, gr := range grules {
+		base := Rule{
+			Source:      RuleSourceGrafana,
+			Type:        "alert",
+			Name:        derefOrEmpty(gr.Title),
+			Group:       derefOrEmpty(gr.RuleGroup),
+			Labels:      labelSetFrom(gr.Labels),
+			Annotations: labelSetFrom(gr.Annotations),
+		}
+		if uid := derefOrEmpty(gr.FolderUID); uid != "" {
+			base.Folder = uid
+			if title, ok := folders[uid]; ok {
+				base.Folder = title
+			}
+		}
+		if gr.For != nil {
+			base.For = time.Duration(*gr.For).Seconds()
+		}
+		if gr.Record != nil {
+			base.Type, base.Name = "record", derefOrEmpty(gr.Record.Metric)
+		}
+		for _, q := range gr.Data {
+			if q == nil || q.DatasourceUID == grafanaExprDatasource {
+				continue
+			}
+			if typ, ok := dsTypes[q.DatasourceUID]; ok && !isPromDatasourceType(typ) {
+				continue
+			}
+			m, _ := q.Model.(map[string]any)
+			expr, _ := m["expr"].(string)
+			if expr == "" {
+				continue
+			}
+			rule := base
+			rule.Query, rule.RefID, rule.Datasource = expr, q.RefID, q.DatasourceUID
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// grafanaExprDatasource is the datasource uid of server side expressions like math, reduce & threshold.
+const grafanaExprDatasource = "__expr__"
+
+// isPromDatasourceType reports whether the datasource plugin type speaks promql.
+func isPromDatasourceType(typ string) bool {
+	return typ == "prometheus" || strings.HasSuffix(typ, "prometheus-datasource")
+}
+
+func labelSetFrom(m map[string]string) model.LabelSet {
+	if len(m) == 0 {
+		return nil
+	}
+	res := make(model.LabelSet, len(m))
+	for k, v := range m {
+		res[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return res
+}
+
+func derefOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
-func writeAllRulesCSV(ctx context.Context, file string, rules promapiv1.RulesResult) error {
+func writeAllRulesCSV(ctx context.Context, file string, rules []Rule) error {
 	f, err := os.Create(file)
 	if err != nil {
 		return fmt.Errorf("create output file: %w", err)
@@ -47,38 +223,41 @@ func writeAllRulesCSV(ctx context.Context, file string, rules promapiv1.RulesRes
 		w:    csv.NewWriter(f),
 	}
 	err = wr.Write(ctx, func(buf []string) {
-		buf[colRuleGroup], buf[colRuleType], buf[colRuleName], buf[colRuleQuery], buf[colRuleLabels], buf[colRuleEvalTime], buf[colRuleLastEval] = "group", "type", "name", "query", "labels", "evalTime", "lastEval"
+		copy(buf, ruleHeaders[:])
 	})
 	if err != nil {
 		return fmt.Errorf("write headers: %w", err)
 	}
-	for _, group := range rules.Groups {
-		for _, rule := range group.Rules {
-			switch r := rule.(type) {
-			case promapiv1.RecordingRule:
-				err = wr.Write(ctx, func(buf []string) {
-					buf[0] = group.Name
-					buf[1], buf[2], buf[3] = "record", r.Name, r.Query
-					buf[4], buf[5], buf[6] = humanizeLabelSet(r.Labels), strconv.FormatFloat(r.EvaluationTime, 'g', -1, 64), r.LastEvaluation.String()
-				})
-				if err != nil {
-					return fmt.Errorf("write rule: %w", err)
-				}
-			case promapiv1.AlertingRule:
-				err = wr.Write(ctx, func(buf []string) {
-					buf[0] = group.Name
-					buf[1], buf[2], buf[3] = "alert", r.Name, r.Query
-					buf[4], buf[5], buf[6] = humanizeLabelSet(r.Labels), strconv.FormatFloat(r.EvaluationTime, 'g', -1, 64), r.LastEvaluation.String()
-				})
-				if err != nil {
-					return fmt.Errorf("write rule: %w", err)
-				}
-			default:
+	for _, r := range rules {
+		// annotations are free text, unlike labels they're written as json
+		var annotations []byte
+		if len(r.Annotations) > 0 {
+			if annotations, err = json.Marshal(r.Annotations); err != nil {
+				return fmt.Errorf("marshal annotations of %q: %w", r.Name, err)
 			}
 		}
+		err = wr.Write(ctx, func(buf []string) {
+			buf[colRuleGroup] = r.Group
+			buf[colRuleType], buf[colRuleName], buf[colRuleQuery] = r.Type, r.Name, r.Query
+			buf[colRuleLabels] = humanizeLabelSet(r.Labels)
+			buf[colRuleEvalTime] = strconv.FormatFloat(r.EvalDuration, 'g', -1, 64)
+			buf[colRuleLastEval] = r.LastEval.Format(time.RFC3339Nano)
+			buf[colRuleHealth], buf[colRuleLastError] = r.Health, r.LastError
+			buf[colRuleState], buf[colRuleActiveAlerts] = r.State, strconv.Itoa(r.ActiveAlerts)
+			buf[colRuleGroupInterval] = strconv.FormatFloat(r.GroupInterval, 'g', -1, 64)
+			buf[colRuleGroupEvalTime] = strconv.FormatFloat(r.GroupEvalDuration, 'g', -1, 64)
+			buf[colRuleGroupLastEval] = r.GroupLastEval.Format(time.RFC3339Nano)
+			buf[colRuleFor] = strconv.FormatFloat(r.For, 'g', -1, 64)
+			buf[colRuleAnnotations] = string(annotations)
+			buf[colRuleSource], buf[colRuleFolder] = r.Source, r.Folder
+			buf[colRuleRefID], buf[colRuleDatasource] = r.RefID, r.Datasource
+		})
+		if err != nil {
+			return fmt.Errorf("write rule: %w", err)
+		}
 	}
 	wr.Flush()
-	return nil
+	return wr.w.Error()
 }
 
 func readAllRulesCSV(ctx context.Context, file string) ([]Rule, []error, error) {
@@ -91,8 +270,9 @@ func readAllRulesCSV(ctx context.Context, file string) ([]Rule, []error, error)
 	}()
 
 	r := csv.NewReader(f)
-	if _, err = r.Read(); err != nil { // reading header
-		return nil, nil, fmt.Errorf("read header: %w", err)
+	h, err := readCSVHeader(r)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var (
@@ -113,23 +293,108 @@ OUT:
 				silentErrs = append(silentErrs, fmt.Errorf("read rule: %w", err))
 				continue
 			}
-			dur, err := strconv.ParseFloat(rec[5], 64)
-			if err != nil {
-				return nil, nil, fmt.Errorf("parse eval-duration: %w", err)
-			}
-			rules = append(rules, Rule{
-				Group:        rec[colRuleGroup],
-				Type:         rec[colRuleType],
-				Name:         rec[colRuleName],
-				Query:        rec[colRuleQuery],
-				Labels:       rec[colRuleLabels],
-				EvalDuration: dur,
-			})
+			rule := Rule{
+				Group:      h.get(rec, ruleHeaders[colRuleGroup]),
+				Type:       h.get(rec, ruleHeaders[colRuleType]),
+				Name:       h.get(rec, ruleHeaders[colRuleName]),
+				Query:      h.get(rec, ruleHeaders[colRuleQuery]),
+				Labels:     parseLabelSet(h.get(rec, ruleHeaders[colRuleLabels])),
+				Health:     h.get(rec, ruleHeaders[colRuleHealth]),
+				LastError:  h.get(rec, ruleHeaders[colRuleLastError]),
+				State:      h.get(rec, ruleHeaders[colRuleState]),
+				Source:     h.get(rec, ruleHeaders[colRuleSource]),
+				Folder:     h.get(rec, ruleHeaders[colRuleFolder]),
+				RefID:      h.get(rec, ruleHeaders[colRuleRefID]),
+				Datasource: h.get(rec, ruleHeaders[colRuleDatasource]),
+			}
+			if s := h.get(rec, ruleHeaders[colRuleEvalTime]); s != "" {
+				if rule.EvalDuration, err = strconv.ParseFloat(s, 64); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse eval-duration of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleActiveAlerts]); s != "" {
+				if rule.ActiveAlerts, err = strconv.Atoi(s); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse active-alerts of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleLastEval]); s != "" {
+				if rule.LastEval, err = parseTime(s); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse last-eval of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleGroupInterval]); s != "" {
+				if rule.GroupInterval, err = strconv.ParseFloat(s, 64); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse group-interval of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleGroupEvalTime]); s != "" {
+				if rule.GroupEvalDuration, err = strconv.ParseFloat(s, 64); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse group-eval-duration of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleGroupLastEval]); s != "" {
+				if rule.GroupLastEval, err = parseTime(s); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse group-last-eval of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleFor]); s != "" {
+				if rule.For, err = strconv.ParseFloat(s, 64); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse for of %q: %w", rule.Name, err))
+				}
+			}
+			if s := h.get(rec, ruleHeaders[colRuleAnnotations]); s != "" {
+				if err = json.Unmarshal([]byte(s), &rule.Annotations); err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse annotations of %q: %w", rule.Name, err))
+				}
+			}
+			rules = append(rules, rule)
 		}
 	}
 	return rules, silentErrs, nil
 }
 
+// parseTime parses RFC3339 times & the time.Time.String format that older exports used.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	const stringLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+	return time.Parse(stringLayout, s)
+}
+
+func humanizeLabelSet(labels model.LabelSet) string {
+	arr := make([]string, 0, len(labels))
+	for name, val := range labels {
+		arr = append(arr, fmt.Sprintf("%s=%s", name, val))
+	}
+	sort.Strings(arr)
+	return strings.Join(arr, ",")
+}
+
+// parseLabelSet parses the labels written by humanizeLabelSet.
+// Commas within values are kept as long as they are not followed by a `name=` pair.
+func parseLabelSet(s string) model.LabelSet {
+	if s == "" {
+		return nil
+	}
+	var (
+		res  = make(model.LabelSet)
+		last model.LabelName
+	)
+	for _, part := range strings.Split(s, ",") {
+		name, val, ok := strings.Cut(part, "=")
+		if ok && model.LabelName(name).IsValid() {
+			last = model.LabelName(name)
+			res[last] = model.LabelValue(val)
+			continue
+		}
+		if last != "" {
+			res[last] += model.LabelValue("," + part)
+		}
+	}
+	return res
+}
+
 func distinctRuleNames(rules []Rule) map[RuleName]struct{} {
 	m := make(map[RuleName]struct{}, len(rules))
 	for _, rule := range rules {
@@ -7,8 +7,8 @@ import (
 )
 
 type SlowestConfig struct {
-	RulesFile string
-	Limit     uint64
+	*Source
+	Limit uint64
 }
 
 type (
@@ -31,7 +31,7 @@ func NewPromRulesSlowest(cfg *SlowestConfig) *PromRulesSlowest {
 }
 
 func (prs *PromRulesSlowest) Get(ctx context.Context) (*SlowestRulesResult, error) {
-	rules, silentErrs, err := readAllRulesCSV(ctx, prs.cfg.RulesFile)
+	rules, silentErrs, err := prs.cfg.readRules(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -39,8 +39,8 @@ func (prs *PromRulesSlowest) Get(ctx context.Context) (*SlowestRulesResult, erro
 		return rules[i].EvalDuration > rules[j].EvalDuration
 	})
 
-	results := make([]SlowRule, prs.cfg.Limit)
-	topk := rules[:prs.cfg.Limit]
+	topk := rules[:min(prs.cfg.Limit, uint64(len(rules)))]
+	results := make([]SlowRule, len(topk))
 	for i, rule := range topk {
 		results[i] = SlowRule{
 			Rule:     rule,
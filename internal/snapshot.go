@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SnapshotSchemaVersion is the version of the snapshot layout written by this version of owl.
+const SnapshotSchemaVersion = 1
+
+const manifestFile = "manifest.json"
+
+// manifestMu serialises manifest updates of exports running concurrently.
+var manifestMu sync.Mutex
+
+type snapshotEntity string
+
+const (
+	entityMetrics    snapshotEntity = "metrics"
+	entityRules      snapshotEntity = "rules"
+	entityDashboards snapshotEntity = "dashboards"
+)
+
+type (
+	// Manifest describes the content of a snapshot directory.
+	Manifest struct {
+		SchemaVersion int                                   `json:"schemaVersion"`
+		Entities      map[snapshotEntity]*SnapshotEntryMeta `json:"entities"`
+	}
+	// SnapshotEntryMeta describes an exported entity file of a snapshot.
+	SnapshotEntryMeta struct {
+		File       string    `json:"file"`
+		Source     string    `json:"source"`
+		ExportedAt time.Time `json:"exportedAt"`
+		Count      int       `json:"count"`
+	}
+)
+
+func readManifest(dir string) (*Manifest, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var mf Manifest
+	if err = json.Unmarshal(bs, &mf); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest: %w", err)
+	}
+	if mf.SchemaVersion > SnapshotSchemaVersion {
+		return nil, fmt.Errorf("unsupported snapshot schema version: %d", mf.SchemaVersion)
+	}
+	return &mf, nil
+}
+
+func writeManifest(dir string, mf *Manifest) error {
+	bs, err := json.MarshalIndent(mf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, manifestFile), bs, 0o644); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// writeSnapshotEntity writes items as json lines into the snapshot directory and registers them in the manifest.
+// Other entities of an existing snapshot are kept, so that each export can fill in its own part.
+func writeSnapshotEntity[T any](ctx context.Context, dir string, entity snapshotEntity, source string, items []T) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create snapshot dir: %w", err)
+	}
+
+	file := string(entity) + ".jsonl"
+	f, err := os.Create(filepath.Join(dir, file))
+	if err != nil {
+		return fmt.Errorf("create %s: %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = enc.Encode(item); err != nil {
+			return fmt.Errorf("encode %s: %w", entity, err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("flush %s: %w", file, err)
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	mf, err := readManifest(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		mf, err = &Manifest{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if mf.Entities == nil {
+		mf.Entities = make(map[snapshotEntity]*SnapshotEntryMeta)
+	}
+	mf.SchemaVersion = SnapshotSchemaVersion
+	mf.Entities[entity] = &SnapshotEntryMeta{
+		File:       file,
+		Source:     source,
+		ExportedAt: time.Now().UTC(),
+		Count:      len(items),
+	}
+	return writeManifest(dir, mf)
+}
+
+// readSnapshotEntity reads the json lines of the entity, lines that can't be decoded are skipped.
+func readSnapshotEntity[T any](ctx context.Context, dir string, entity snapshotEntity) ([]T, []error, error) {
+	mf, err := readManifest(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta, ok := mf.Entities[entity]
+	if !ok {
+		return nil, nil, fmt.Errorf("snapshot has no %s", entity)
+	}
+	f, err := os.Open(filepath.Join(dir, meta.File))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open %s: %w", entity, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var (
+		items      []T
+		silentErrs []error
+		r          = bufio.NewReader(f)
+	)
+	for line := 1; ; line++ {
+		if err = ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		bs, err := r.ReadBytes('\n')
+		if len(bytes.TrimSpace(bs)) > 0 {
+			var item T
+			if err := json.Unmarshal(bs, &item); err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("decode %s at line %d: %w", entity, line, err))
+			} else {
+				items = append(items, item)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s: %w", entity, err)
+		}
+	}
+	return items, silentErrs, nil
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"context"
+	"os"
+)
+
+// Source locates the exported entities analysers read.
+// A snapshot directory takes precedence over the csv files of older exports.
+type Source struct {
+	Snapshot                               string
+	RulesFile, MetricsFile, DashboardsFile string
+}
+
+func (s *Source) readMetrics(ctx context.Context) (Metrics, []error, error) {
+	if s.Snapshot == "" {
+		metrics, err := readAllMetricsCSV(ctx, s.MetricsFile)
+		return metrics, nil, err
+	}
+	list, silentErrs, err := readSnapshotEntity[*Metric](ctx, s.Snapshot, entityMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	return metricsFrom(list), silentErrs, nil
+}
+
+// hasMetrics reports whether metrics were exported, for analysers that only refine their results with them.
+// Unreadable snapshots are left for readMetrics to report.
+func (s *Source) hasMetrics() bool {
+	if s.Snapshot == "" {
+		_, err := os.Stat(s.MetricsFile)
+		return err == nil
+	}
+	mf, err := readManifest(s.Snapshot)
+	if err != nil {
+		return true
+	}
+	_, ok := mf.Entities[entityMetrics]
+	return ok
+}
+
+func (s *Source) readRules(ctx context.Context) ([]Rule, []error, error) {
+	if s.Snapshot == "" {
+		return readAllRulesCSV(ctx, s.RulesFile)
+	}
+	return readSnapshotEntity[Rule](ctx, s.Snapshot, entityRules)
+}
+
+func (s *Source) readBoards(ctx context.Context) ([]*Board, []error, error) {
+	if s.Snapshot == "" {
+		return readAllBoardsCSV(ctx, s.DashboardsFile)
+	}
+	return readSnapshotEntity[*Board](ctx, s.Snapshot, entityDashboards)
+}
@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type StaleConfig struct {
+	*Source
+	VarsFile string
+	// Days is the number of days without an edit from which on a dashboard is considered stale.
+	// Views only rule dashboards out when the days span grafana's recent views window of 30 days.
+	Days  uint
+	Limit uint64
+}
+
+type (
+	StaleResult struct {
+		// Boards are the stale dashboards, the most abandoned first.
+		Boards    []StaleBoard
+		ParseErrs []error
+	}
+	StaleBoard struct {
+		Board *Board
+		// Age is the time since the last edit.
+		Age            time.Duration
+		UsedMetrics    int
+		MissingMetrics int
+		// Score is the mean of the signals known for the dashboard, each between 0 & 1:
+		// the age relative to twice the stale days, the lack of views in grafana's recent window,
+		// the few versions as 1/log2(version+1), so that a dashboard edited once scores 1 & one of 255 versions 0.125,
+		// & the share of missing metrics.
+		Score float64
+	}
+)
+
+type DashboardsStaleFinder struct {
+	cfg *StaleConfig
+}
+
+func NewDashboardsStaleFinder(cfg *StaleConfig) *DashboardsStaleFinder {
+	return &DashboardsStaleFinder{cfg: cfg}
+}
+
+// List returns the dashboards not edited for the configured days and, where views are known, not viewed recently,
+// scored by how abandoned they look combined with the metrics they use that don't exist anymore.
+// Views are those of grafana's recent window rather than of the configured days, so they only rule out dashboards
+// when the days span the window. A dashboard viewed 3 weeks ago is still stale by 7 days.
+func (sf *DashboardsStaleFinder) List(ctx context.Context) (*StaleResult, error) {
+	metrics, silentErrs, err := sf.cfg.readMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules, se, err := sf.cfg.readRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	boards, se, err := sf.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	silentErrs = append(silentErrs, se...)
+	vars, err := readVariablesFile(sf.cfg.VarsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now   = time.Now()
+		days  = time.Duration(sf.cfg.Days) * 24 * time.Hour
+		names = distinctRuleNames(rules)
+		res   []StaleBoard
+	)
+	for _, board := range boards {
+		// dashboards exported by older versions lack the meta
+		if board.Updated.IsZero() {
+			continue
+		}
+		age := now.Sub(board.Updated)
+		if age < days || (board.Views != nil && *board.Views > 0 && days >= recentViewsWindow) {
+			continue
+		}
+		bvars := mergeVariables(board.Variables(), vars)
+		used, se := boardUsedMetrics(board, bvars)
+		silentErrs = append(silentErrs, se...)
+		missings, _ := boardMissingMetrics(board, bvars, names, metrics)
+
+		sb := StaleBoard{
+			Board: &Board{
+				UID:     board.UID,
+				Title:   board.Title,
+				Folder:  board.Folder,
+				Updated: board.Updated,
+				Version: board.Version,
+				Views:   board.Views,
+			},
+			Age:            age,
+			UsedMetrics:    len(used),
+			MissingMetrics: len(missings),
+		}
+		signals := []float64{min(age.Hours()/max(2*days.Hours(), 1), 1)}
+		// no views in the recent window confirm a dashboard is abandoned
+		if board.Views != nil {
+			viewless := 0.0
+			if *board.Views == 0 {
+				viewless = 1
+			}
+			signals = append(signals, viewless)
+		}
+		// dashboards left after a few edits were likely never adopted, the version is unknown for older exports
+		if board.Version > 0 {
+			signals = append(signals, 1/math.Log2(float64(board.Version)+1))
+		}
+		if len(used) > 0 {
+			signals = append(signals, float64(len(missings))/float64(len(used)))
+		}
+		for _, s := range signals {
+			sb.Score += s
+		}
+		sb.Score /= float64(len(signals))
+		res = append(res, sb)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].Age > res[j].Age
+	})
+	return &StaleResult{
+		Boards:    res[:min(sf.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+// boardUsedMetrics returns the distinct metric names the queries of the board select.
+func boardUsedMetrics(board *Board, vars Variables) (map[MetricName]struct{}, []error) {
+	var silentErrs []error
+	used := make(map[MetricName]struct{})
+	for _, panel := range board.AllPanels() {
+		for _, target := range panel.Targets {
+			if target.Expr == "" {
+				continue
+			}
+			pq, err := parsePromQuery(target.Expr, vars)
+			if err != nil {
+				silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
+				continue
+			}
+			for _, m := range pq.names {
+				used[m] = struct{}{}
+			}
+		}
+	}
+	return used, silentErrs
+}
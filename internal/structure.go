@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+type StructureConfig struct {
+	*Source
+	// MinSchemaVersion is the oldest dashboard schema version not reported.
+	MinSchemaVersion uint
+	Limit            uint64
+}
+
+// IDs of the structure checks.
+const (
+	StructureOldSchema        = "old-schema-version"
+	StructureDuplicatePanelID = "duplicate-panel-id"
+	StructureNoTargets        = "panel-without-targets"
+	StructureEmptyExpr        = "empty-expr"
+	StructureDuplicateRefID   = "duplicate-ref-id"
+	StructureHiddenQuery      = "hidden-query"
+)
+
+type (
+	StructureResult struct {
+		// Boards are the dashboards with issues, the ones with the most first.
+		Boards    []BoardIssues
+		ParseErrs []error
+	}
+	BoardIssues struct {
+		Board  *Board
+		Issues []StructureIssue
+	}
+	StructureIssue struct {
+		Check string
+		// PanelID & RefID are empty for issues of the dashboard.
+		PanelID uint
+		RefID   string
+		Message string
+	}
+)
+
+// querylessPanelTypes are the panel types that show no query results.
+var querylessPanelTypes = map[string]struct{}{
+	"row":            {},
+	"text":           {},
+	"dashlist":       {},
+	"news":           {},
+	"alertlist":      {},
+	"annolist":       {},
+	"welcome":        {},
+	"gettingstarted": {},
+}
+
+type DashboardsStructureAuditor struct {
+	cfg *StructureConfig
+}
+
+func NewDashboardsStructureAuditor(cfg *StructureConfig) *DashboardsStructureAuditor {
+	return &DashboardsStructureAuditor{cfg: cfg}
+}
+
+// Audit checks the schema version of every dashboard, the ids of its panels, including the ones of collapsed rows,
+// and the targets of the panels for missing, empty, hidden & duplicate queries.
+func (sa *DashboardsStructureAuditor) Audit(ctx context.Context) (*StructureResult, error) {
+	boards, silentErrs, err := sa.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []BoardIssues
+	for _, board := range boards {
+		if issues := sa.audit(board); len(issues) > 0 {
+			res = append(res, BoardIssues{
+				Board: &Board{
+					UID:           board.UID,
+					Title:         board.Title,
+					Folder:        board.Folder,
+					FolderUID:     board.FolderUID,
+					SchemaVersion: board.SchemaVersion,
+				},
+				Issues: issues,
+			})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool { return len(res[i].Issues) > len(res[j].Issues) })
+	return &StructureResult{
+		Boards:    res[:min(sa.cfg.Limit, uint64(len(res)))],
+		ParseErrs: silentErrs,
+	}, nil
+}
+
+func (sa *DashboardsStructureAuditor) audit(board *Board) []StructureIssue {
+	var res []StructureIssue
+	add := func(check string, panel *Panel, refID string, format string, args ...any) {
+		is := StructureIssue{Check: check, RefID: refID, Message: fmt.Sprintf(format, args...)}
+		if panel != nil {
+			is.PanelID = panel.ID
+		}
+		res = append(res, is)
+	}
+
+	// the schema version is unknown for exports of older versions
+	if board.SchemaVersion > 0 && board.SchemaVersion < sa.cfg.MinSchemaVersion {
+		add(StructureOldSchema, nil, "", "schema version %d is older than %d", board.SchemaVersion, sa.cfg.MinSchemaVersion)
+	}
+	ids := make(map[uint]int)
+	for _, panel := range board.AllPanels() {
+		ids[panel.ID]++
+		if ids[panel.ID] == 2 {
+			add(StructureDuplicatePanelID, panel, "", "panel id %d is used more than once", panel.ID)
+		}
+		if _, ok := querylessPanelTypes[panel.Type]; ok {
+			continue
+		}
+		if len(panel.Targets) == 0 {
+			add(StructureNoTargets, panel, "", "%s panel %q has no queries", panel.Type, panel.Title)
+			continue
+		}
+		refIDs := make(map[string]int)
+		for _, target := range panel.Targets {
+			if refIDs[target.RefID]++; refIDs[target.RefID] == 2 && target.RefID != "" {
+				add(StructureDuplicateRefID, panel, target.RefID, "ref id %s is used more than once", target.RefID)
+			}
+			switch {
+			case target.Hide:
+				add(StructureHiddenQuery, panel, target.RefID, "query is disabled, it isn't run but kept in the dashboard")
+			case target.Expr == "" && isPromDatasource(target.Datasource):
+				add(StructureEmptyExpr, panel, target.RefID, "query has no expression")
+			}
+		}
+	}
+	return res
+}
+
+// isPromDatasource reports whether the datasource is a prometheus one or unknown,
+// it's either the name of the datasource or a reference holding its type.
+func isPromDatasource(ds any) bool {
+	ref, ok := ds.(map[string]any)
+	if !ok {
+		return true
+	}
+	typ, _ := ref["type"].(string)
+	return typ == "" || typ == "prometheus"
+}
@@ -2,17 +2,14 @@ package internal
 
 import (
 	"context"
-	"encoding/csv"
-	"encoding/json"
 	"fmt"
-	"io"
-	"os"
 	"sort"
 )
 
 type TopListerConfig struct {
-	DashboardsFile string
-	Limit          uint64
+	*Source
+	VarsFile string
+	Limit    uint64
 }
 
 type (
@@ -35,52 +32,46 @@ func NewTopUsedListerInGrafana(cfg *TopListerConfig) *TopUsedListerInGrafana {
 }
 
 func (tl *TopUsedListerInGrafana) List(ctx context.Context) (*TopUsedResult, error) {
-	f, err := os.Open(tl.cfg.DashboardsFile)
+	// metrics only resolve the regex matchers of names, top used works with dashboards alone
+	var (
+		known      Metrics
+		silentErrs []error
+		err        error
+	)
+	if tl.cfg.hasMetrics() {
+		if known, silentErrs, err = tl.cfg.readMetrics(ctx); err != nil {
+			return nil, err
+		}
+	}
+	vars, err := readVariablesFile(tl.cfg.VarsFile)
 	if err != nil {
-		return nil, fmt.Errorf("open dashboards: %w", err)
+		return nil, err
 	}
-	defer func() {
-		_ = f.Close()
-	}()
-
-	r := csv.NewReader(f)
-	if _, err = r.Read(); err != nil { // reading header
-		return nil, fmt.Errorf("read header: %w", err)
+	boards, se, err := tl.cfg.readBoards(ctx)
+	if err != nil {
+		return nil, err
 	}
+	silentErrs = append(silentErrs, se...)
 
 	metrics := make(map[MetricName]uint32)
-	var silentErrs []error
-OUT:
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-			board, err := r.Read()
-			if err == io.EOF {
-				break OUT
-			}
-			if err != nil {
-				return nil, fmt.Errorf("read dashboard: %w", err)
-			}
-
-			var panels []*Panel
-			if err := json.Unmarshal([]byte(board[colBoardPanels]), &panels); err != nil {
-				return nil, fmt.Errorf("unmarshal panel: %w", err)
-			}
-			for _, panel := range panels {
-				for _, target := range panel.Targets {
-					if target.Expr == "" {
-						continue
-					}
-					ms, err := parsePromQuery(target.Expr)
-					if err != nil {
-						silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
-						continue
-					}
-					for _, m := range ms {
-						metrics[m]++
-					}
+	for _, board := range boards {
+		bvars := mergeVariables(board.Variables(), vars)
+		for _, panel := range board.AllPanels() {
+			for _, target := range panel.Targets {
+				if target.Expr == "" {
+					continue
+				}
+				pq, err := parsePromQuery(target.Expr, bvars)
+				if err != nil {
+					silentErrs = append(silentErrs, fmt.Errorf("parse expr: %w", err))
+					continue
+				}
+				names := pq.names
+				if known != nil {
+					names = pq.resolve(known)
+				}
+				for _, m := range names {
+					metrics[m]++
 				}
 			}
 		}
@@ -97,7 +88,7 @@ OUT:
 		return usages[i].Used > usages[j].Used
 	})
 	return &TopUsedResult{
-		Usages:    usages[:tl.cfg.Limit],
+		Usages:    usages[:min(tl.cfg.Limit, uint64(len(usages)))],
 		ParseErrs: silentErrs,
 	}, nil
 }
@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Variables maps Grafana variable names to the values they are replaced with before queries are parsed.
+type Variables map[string]string
+
+// multiValueSep separates the values of multi-value variables, which are joined to a regex only inside regex matchers.
+const multiValueSep = "\x1f"
+
+var (
+	// variableRefRegex matches $var, ${var}, ${var:format} and [[var]] references.
+	variableRefRegex = regexp.MustCompile(`\$\{(\w+)(?::\w+)?}|\[\[(\w+)(?::\w+)?]]|\$(\w+)`)
+	// regexMatcherPrefixRegex matches the query up to a reference inside the value of a =~ or !~ matcher.
+	regexMatcherPrefixRegex = regexp.MustCompile("[=!]~\\s*(\"[^\"]*|'[^']*|`[^`]*)$")
+	defaultVariables        = Variables{
+		"__interval":      "5m",
+		"interval":        "5m",
+		"resolution":      "5s",
+		"__rate_interval": "15s",
+		"rate_interval":   "15s",
+		"__range":         "1d",
+		"__range_s":       "30",
+	}
+)
+
+func readVariablesFile(file string) (Variables, error) {
+	if file == "" {
+		return nil, nil
+	}
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read variables: %w", err)
+	}
+	var vars Variables
+	if err = yaml.Unmarshal(bs, &vars); err != nil {
+		return nil, fmt.Errorf("unmarshal variables: %w", err)
+	}
+	return vars, nil
+}
+
+// mergeVariables merges given variables into a new one, latter ones take precedence.
+func mergeVariables(vs ...Variables) Variables {
+	res := make(Variables)
+	for _, v := range vs {
+		for name, val := range v {
+			res[name] = val
+		}
+	}
+	return res
+}
+
+// replace substitutes the known variable references in the query, unknown ones are left as is.
+// Multi-value variables are replaced with their values joined by | inside =~ & !~ matchers, with their first value elsewhere.
+func (v Variables) replace(query string) string {
+	var (
+		sb   strings.Builder
+		last int
+	)
+	for _, loc := range variableRefRegex.FindAllStringSubmatchIndex(query, -1) {
+		var name string
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] >= 0 {
+				name = query[loc[i]:loc[i+1]]
+			}
+		}
+		val, ok := v[name]
+		if !ok {
+			val, ok = defaultVariables[name]
+		}
+		if !ok {
+			continue
+		}
+		vals := strings.Split(val, multiValueSep)
+		if regexMatcherPrefixRegex.MatchString(query[:loc[0]]) {
+			val = strings.Join(vals, "|")
+		} else {
+			val = vals[0]
+		}
+		sb.WriteString(query[last:loc[0]])
+		sb.WriteString(val)
+		last = loc[1]
+	}
+	sb.WriteString(query[last:])
+	return sb.String()
+}
+
+// Variables returns the values of the dashboard's templating variables.
+// Current values are preferred, the query of custom, constant & interval variables are used as fallback.
+func (b *Board) Variables() Variables {
+	vars := make(Variables, len(b.Templating.List))
+	for _, tv := range b.Templating.List {
+		if tv == nil || tv.Name == "" {
+			continue
+		}
+		if val, ok := templateValue(tv.Current.Value); ok {
+			vars[tv.Name] = val
+			continue
+		}
+		switch tv.Type {
+		case "custom", "constant", "textbox", "interval":
+			if q, ok := tv.Query.(string); ok && q != "" {
+				first, _, _ := strings.Cut(q, ",")
+				vars[tv.Name] = strings.TrimSpace(first)
+			}
+		}
+	}
+	return vars
+}
+
+func templateValue(val any) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		if v == "" {
+			return "", false
+		}
+		if v == "$__all" {
+			return ".*", true
+		}
+		return v, true
+	case []any:
+		vals := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := templateValue(item)
+			if !ok {
+				continue
+			}
+			if s == ".*" {
+				return s, true
+			}
+			vals = append(vals, s)
+		}
+		if len(vals) == 0 {
+			return "", false
+		}
+		return strings.Join(vals, multiValueSep), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,34 @@
+package internal
+
+import "testing"
+
+func TestVariablesReplaceMultiValue(t *testing.T) {
+	board := &Board{}
+	board.Templating.List = []*TemplateVar{
+		{Name: "cluster", Type: "query"},
+		{Name: "job", Type: "query"},
+	}
+	board.Templating.List[0].Current.Value = []any{"eu", "us"}
+	board.Templating.List[1].Current.Value = []any{"$__all"}
+	vars := board.Variables()
+
+	tests := []struct {
+		name, query, want string
+	}{
+		{name: "regex matcher", query: `up{cluster=~"$cluster"}`, want: `up{cluster=~"eu|us"}`},
+		{name: "negative regex matcher", query: `up{cluster!~"${cluster}"}`, want: `up{cluster!~"eu|us"}`},
+		{name: "equality matcher", query: `up{cluster="$cluster"}`, want: `up{cluster="eu"}`},
+		{name: "after a regex matcher", query: `up{job=~"a", cluster="$cluster"}`, want: `up{job=~"a", cluster="eu"}`},
+		{name: "label value", query: `label_replace(up, "c", "$cluster", "", "")`, want: `label_replace(up, "c", "eu", "", "")`},
+		{name: "all", query: `up{job=~"$job"}`, want: `up{job=~".*"}`},
+		{name: "defaults", query: `rate(up[$__rate_interval])`, want: `rate(up[15s])`},
+		{name: "unknown", query: `up{x="$unknown"}`, want: `up{x="$unknown"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vars.replace(tt.query); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}